@@ -5,9 +5,12 @@ import (
 	"flag"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/citadel/citadel"
 	"github.com/citadel/citadel/cluster"
+	"github.com/citadel/citadel/profiler"
 	"github.com/citadel/citadel/scheduler"
 	"github.com/gorilla/mux"
 )
@@ -16,6 +19,7 @@ var (
 	configPath     string
 	config         *Config
 	clusterManager *cluster.Cluster
+	imageProfiler  *profiler.Profiler
 )
 
 func init() {
@@ -54,7 +58,29 @@ func run(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	container, err := clusterManager.Start(image, false)
+	var (
+		container *citadel.Container
+		err       error
+	)
+
+	// queued images wait until there is capacity for them
+	if r.FormValue("queue") == "true" {
+		p := clusterManager.Enqueue(image, image.Priority, false, nil)
+
+		select {
+		case <-p.Done():
+		case <-r.Context().Done():
+			// the client went away so nobody is waiting for the image anymore
+			if clusterManager.Dequeue(p) {
+				return
+			}
+		}
+
+		container, err = p.Wait()
+	} else {
+		container, err = clusterManager.Start(image, false)
+	}
+
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 
@@ -69,6 +95,195 @@ func run(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// groupRequest is the body of a request to run replicas of an image
+type groupRequest struct {
+	Image    *citadel.Image `json:"image,omitempty"`
+	Count    int            `json:"count,omitempty"`
+	Strategy string         `json:"strategy,omitempty"`
+}
+
+func explain(w http.ResponseWriter, r *http.Request) {
+	var image *citadel.Image
+	if err := json.NewDecoder(r.Body).Decode(&image); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	explanation, err := clusterManager.Explain(image)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(explanation); err != nil {
+		log.Println(err)
+	}
+}
+
+func runGroup(w http.ResponseWriter, r *http.Request) {
+	var req *groupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	if req.Image == nil {
+		http.Error(w, "image is required", http.StatusBadRequest)
+
+		return
+	}
+
+	containers, err := clusterManager.StartGroup(req.Image, req.Count, req.Strategy, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	if err := json.NewEncoder(w).Encode(containers); err != nil {
+		log.Println(err)
+	}
+}
+
+func runGang(w http.ResponseWriter, r *http.Request) {
+	var images []*citadel.Image
+	if err := json.NewDecoder(r.Body).Decode(&images); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	containers, err := clusterManager.StartGang(images, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	if err := json.NewEncoder(w).Encode(containers); err != nil {
+		log.Println(err)
+	}
+}
+
+func services(w http.ResponseWriter, r *http.Request) {
+	services, err := clusterManager.Services()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(services); err != nil {
+		log.Println(err)
+	}
+}
+
+func saveService(w http.ResponseWriter, r *http.Request) {
+	var service *citadel.Service
+	if err := json.NewDecoder(r.Body).Decode(&service); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	if err := clusterManager.SaveService(service); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func removeService(w http.ResponseWriter, r *http.Request) {
+	if err := clusterManager.RemoveService(mux.Vars(r)["name"]); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// jobRequest is the body of a request to run a batch job
+type jobRequest struct {
+	Image   *citadel.Image `json:"image,omitempty"`
+	Retries int            `json:"retries,omitempty"`
+}
+
+func runJob(w http.ResponseWriter, r *http.Request) {
+	var req *jobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	if req.Image == nil {
+		http.Error(w, "image is required", http.StatusBadRequest)
+
+		return
+	}
+
+	job, err := clusterManager.RunJob(req.Image, req.Retries)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		log.Println(err)
+	}
+}
+
+func jobs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(clusterManager.Jobs()); err != nil {
+		log.Println(err)
+	}
+}
+
+func job(w http.ResponseWriter, r *http.Request) {
+	job, err := clusterManager.Job(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		log.Println(err)
+	}
+}
+
+func tenants(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(clusterManager.Tenants()); err != nil {
+		log.Println(err)
+	}
+}
+
 func engines(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("content-type", "application/json")
 
@@ -86,6 +301,40 @@ func containers(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func profiles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(imageProfiler.Profiles()); err != nil {
+		log.Println(err)
+	}
+}
+
+func profileReport(w http.ResponseWriter, r *http.Request) {
+	tolerance := 0.2
+	if t := r.FormValue("tolerance"); t != "" {
+		v, err := strconv.ParseFloat(t, 64)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+		tolerance = v
+	}
+
+	w.Header().Set("content-type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(imageProfiler.Report(tolerance)); err != nil {
+		log.Println(err)
+	}
+}
+
+// collectProfiles records the usage sampled by the cluster in the image profiler
+func collectProfiles() {
+	for range time.Tick(15 * time.Second) {
+		imageProfiler.Collect(clusterManager.Engines())
+	}
+}
+
 func main() {
 	if err := loadConfig(); err != nil {
 		log.Fatal(err)
@@ -96,38 +345,77 @@ func main() {
 		log.Fatal(err)
 	}
 
+	reg, err := getRegistry()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := mergeEngines(reg); err != nil {
+		log.Fatal(err)
+	}
+
 	for _, d := range config.Engines {
 		if err := setEngineClient(d, tlsConfig); err != nil {
 			log.Fatal(err)
 		}
 	}
 
-	clusterManager, err = cluster.New(scheduler.NewResourceManager(), config.Engines...)
+	resourceManager := scheduler.NewResourceManager()
+	if config.LiveUsage {
+		resourceManager = scheduler.NewUsageResourceManager(config.UsageHeadroom)
+	}
+	resourceManager.Preemption = config.Preemption
+
+	for tpe, name := range config.Strategies {
+		strategy, err := scheduler.ParseStrategy(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		resourceManager.SetStrategy(tpe, strategy)
+	}
+
+	imageProfiler = profiler.New(profiler.DefaultSamples)
+	if config.RightSize {
+		resourceManager.Estimator = imageProfiler
+	}
+
+	clusterManager, err = cluster.New(resourceManager, reg, config.Engines...)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	var (
-		labelScheduler  = &scheduler.LabelScheduler{}
-		uniqueScheduler = &scheduler.UniqueScheduler{}
-		hostScheduler   = &scheduler.HostScheduler{}
-
-		multiScheduler = scheduler.NewMultiScheduler(
-			labelScheduler,
-			uniqueScheduler,
-		)
-	)
+	for tenant, q := range config.Quotas {
+		clusterManager.SetQuota(tenant, q)
+	}
+
+	if config.FairShare {
+		clusterManager.SetAllocator(&scheduler.DRFAllocator{})
+	}
+
+	if err := registerSchedulers(); err != nil {
+		log.Fatal(err)
+	}
 
-	clusterManager.RegisterScheduler("service", labelScheduler)
-	clusterManager.RegisterScheduler("unique", uniqueScheduler)
-	clusterManager.RegisterScheduler("multi", multiScheduler)
-	clusterManager.RegisterScheduler("host", hostScheduler)
+	go collectProfiles()
 
 	r := mux.NewRouter()
 	r.HandleFunc("/containers", containers).Methods("GET")
 	r.HandleFunc("/run", run).Methods("POST")
+	r.HandleFunc("/run/group", runGroup).Methods("POST")
+	r.HandleFunc("/run/gang", runGang).Methods("POST")
+	r.HandleFunc("/explain", explain).Methods("POST")
 	r.HandleFunc("/destroy", destroy).Methods("DELETE")
+	r.HandleFunc("/services", services).Methods("GET")
+	r.HandleFunc("/services", saveService).Methods("POST")
+	r.HandleFunc("/services/{name}", removeService).Methods("DELETE")
+	r.HandleFunc("/jobs", jobs).Methods("GET")
+	r.HandleFunc("/jobs", runJob).Methods("POST")
+	r.HandleFunc("/jobs/{id}", job).Methods("GET")
+	r.HandleFunc("/tenants", tenants).Methods("GET")
 	r.HandleFunc("/engines", engines).Methods("GET")
+	r.HandleFunc("/profiles", profiles).Methods("GET")
+	r.HandleFunc("/profiles/report", profileReport).Methods("GET")
 
 	log.Printf("bastion listening on %s\n", config.ListenAddr)
 
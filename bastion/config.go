@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/citadel/citadel"
+	"github.com/citadel/citadel/scheduler"
 )
 
 type Config struct {
@@ -13,6 +14,30 @@ type Config struct {
 	CACertificate  string            `json:"ca-cert,omitempty"`
 	ListenAddr     string            `json:"listen-addr,omitempty"`
 	Engines        []*citadel.Engine `json:"engines,omitempty"`
+	RegistryPath   string            `json:"registry,omitempty"`
+	LiveUsage      bool              `json:"live-usage,omitempty"`
+	UsageHeadroom  float64           `json:"usage-headroom,omitempty"`
+	RightSize      bool              `json:"right-size,omitempty"`
+	Strategies     map[string]string `json:"strategies,omitempty"`
+	Preemption     bool              `json:"preemption,omitempty"`
+	FairShare      bool              `json:"fair-share,omitempty"`
+
+	Quotas map[string]*citadel.Quota `json:"quotas,omitempty"`
+
+	// Schedulers are the scheduler pipelines of each type, replacing the default
+	// pipeline of the type
+	Schedulers map[string][]*scheduler.Stage `json:"schedulers,omitempty"`
+}
+
+// defaultSchedulers are the pipelines of the types that are not configured.  Every type
+// checks that the host ports bound by the image are free, that the named volumes it
+// binds are on the engine and that its affinities are met.
+var defaultSchedulers = map[string][]string{
+	"service": {"label", "port", "volume", "affinity"},
+	"unique":  {"unique", "port", "volume", "affinity"},
+	"multi":   {"label", "unique", "port", "volume", "affinity"},
+	"host":    {"host", "port", "volume", "affinity"},
+	"batch":   {"label", "port", "volume", "affinity"},
 }
 
 func loadConfig() error {
@@ -3,11 +3,14 @@ package main
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"fmt"
 	"io/ioutil"
 	"net/url"
 	"os"
 
 	"github.com/citadel/citadel"
+	"github.com/citadel/citadel/registry"
+	"github.com/citadel/citadel/scheduler"
 )
 
 func getTLSConfig() (*tls.Config, error) {
@@ -49,3 +52,66 @@ func setEngineClient(docker *citadel.Engine, tlsConfig *tls.Config) error {
 
 	return docker.Connect(tc)
 }
+
+// getRegistry returns a file backed registry when a path is configured
+// otherwise the cluster state is only kept in memory
+func getRegistry() (citadel.Registry, error) {
+	if config.RegistryPath == "" {
+		return registry.NewMemoryRegistry(), nil
+	}
+
+	return registry.NewFileRegistry(config.RegistryPath)
+}
+
+// mergeEngines adds engines saved in the registry by a previous run that
+// are not part of the config
+func mergeEngines(reg citadel.Registry) error {
+	saved, err := reg.FetchEngines()
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]bool)
+	for _, e := range config.Engines {
+		known[e.ID] = true
+	}
+
+	for _, e := range saved {
+		if !known[e.ID] {
+			config.Engines = append(config.Engines, e)
+		}
+	}
+
+	return nil
+}
+
+// registerSchedulers registers the scheduler pipeline of every type with the cluster.
+// Every type prefers engines matching the preferences of the image.
+func registerSchedulers() error {
+	pipelines := make(map[string][]*scheduler.Stage)
+
+	for tpe, names := range defaultSchedulers {
+		for _, name := range names {
+			pipelines[tpe] = append(pipelines[tpe], &scheduler.Stage{Name: name})
+		}
+	}
+
+	for tpe, stages := range config.Schedulers {
+		pipelines[tpe] = stages
+	}
+
+	for tpe, stages := range pipelines {
+		s, err := scheduler.NewPipeline(stages)
+		if err != nil {
+			return fmt.Errorf("scheduler pipeline for type %s: %s", tpe, err)
+		}
+
+		s.AddScorer(&scheduler.PreferenceScorer{}, 1)
+
+		if err := clusterManager.RegisterScheduler(tpe, s); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -7,10 +7,16 @@ import (
 	"sync"
 
 	"github.com/citadel/citadel"
+	"github.com/citadel/citadel/registry"
 )
 
+// maxCommitAttempts is the number of times a placement is evaluated against fresh
+// engine state before giving up
+const maxCommitAttempts = 5
+
 var (
-	ErrEngineNotConnected = errors.New("engine is not connected to docker's REST API")
+	ErrEngineNotConnected = citadel.ErrEngineNotConnected
+	ErrConflict           = errors.New("engine state changed before the placement could be committed")
 )
 
 type Cluster struct {
@@ -19,32 +25,94 @@ type Cluster struct {
 	engines         map[string]*citadel.Engine
 	schedulers      map[string]citadel.Scheduler
 	resourceManager citadel.ResourceManager
+	registry        citadel.Registry
+	ledger          *ledger
+	handlers        []citadel.EventHandler
+	subscribed      map[string]bool
+	done            chan struct{}
+
+	// moved are the containers of unreachable engines by engine id that were
+	// rescheduled on other engines
+	moved map[string]map[string]bool
+
+	// unmoved are the containers of unreachable engines by engine id that failed to be
+	// rescheduled and are retried on every health check
+	unmoved map[string]map[string]bool
+
+	jobMux        sync.Mutex
+	jobs          map[string]*citadel.Job
+	jobContainers map[string]*citadel.Job
+
+	queueMux     sync.Mutex
+	queue        []*Pending
+	queueSeq     uint64
+	queueChanged chan struct{}
+	allocator    citadel.Allocator
+
+	// serviceMux serializes converging the containers of the services
+	serviceMux      sync.Mutex
+	servicesChanged chan struct{}
 }
 
-func New(manager citadel.ResourceManager, engines ...*citadel.Engine) (*Cluster, error) {
+// New returns a cluster for the engines that records its state in the registry.
+// If registry is nil the state is only kept in memory.
+func New(manager citadel.ResourceManager, reg citadel.Registry, engines ...*citadel.Engine) (*Cluster, error) {
+	if reg == nil {
+		reg = registry.NewMemoryRegistry()
+	}
+
 	c := &Cluster{
 		engines:         make(map[string]*citadel.Engine),
 		schedulers:      make(map[string]citadel.Scheduler),
 		resourceManager: manager,
+		registry:        reg,
+		ledger:          newLedger(reg),
+		subscribed:      make(map[string]bool),
+		moved:           make(map[string]map[string]bool),
+		unmoved:         make(map[string]map[string]bool),
+		jobs:            make(map[string]*citadel.Job),
+		jobContainers:   make(map[string]*citadel.Job),
+		queueChanged:    make(chan struct{}, 1),
+		done:            make(chan struct{}),
+		servicesChanged: make(chan struct{}, 1),
 	}
 
 	for _, e := range engines {
-		if !e.IsConnected() {
-			return nil, ErrEngineNotConnected
+		if err := c.addEngine(e); err != nil {
+			return nil, err
 		}
+	}
+
+	if err := c.ledger.load(); err != nil {
+		return nil, err
+	}
 
-		c.engines[e.ID] = e
+	// engines that are not reachable are still added to the cluster and are
+	// connected by the health checks once they respond
+	for _, e := range engines {
+		e.CheckHealth()
+
+		if err := c.connect(e); err != nil {
+			return nil, err
+		}
 	}
 
+	go c.reconcileLoop()
+	go c.healthLoop()
+	go c.usageLoop()
+	go c.serviceLoop()
+	go c.jobLoop()
+	go c.queueLoop()
+
 	return c, nil
 }
 
+// Events registers a handler that receives the events of every engine in the cluster
 func (c *Cluster) Events(handler citadel.EventHandler) error {
-	for _, e := range c.engines {
-		if err := e.Events(handler); err != nil {
-			return err
-		}
-	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.handlers = append(c.handlers, handler)
 
 	return nil
 }
@@ -60,9 +128,56 @@ func (c *Cluster) RegisterScheduler(tpe string, s citadel.Scheduler) error {
 
 func (c *Cluster) AddEngine(e *citadel.Engine) error {
 	c.mux.Lock()
-	defer c.mux.Unlock()
+	err := c.addEngine(e)
+	c.mux.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	e.CheckHealth()
+
+	if err := c.connect(e); err != nil {
+		return err
+	}
+
+	c.processQueue()
+
+	return nil
+}
+
+func (c *Cluster) addEngine(e *citadel.Engine) error {
+	if err := c.registry.SaveEngine(e); err != nil {
+		return err
+	}
 
 	c.engines[e.ID] = e
+	c.ledger.addEngine(e.ID)
+
+	return nil
+}
+
+// connect subscribes to the events of a reachable engine and brings its ledger up to
+// date with docker.  It does nothing if the engine is not reachable or already connected.
+func (c *Cluster) connect(e *citadel.Engine) error {
+	c.mux.Lock()
+	if !e.IsConnected() || c.subscribed[e.ID] {
+		c.mux.Unlock()
+
+		return nil
+	}
+
+	if err := e.Events(&engineEvents{c: c}); err != nil {
+		c.mux.Unlock()
+
+		return err
+	}
+
+	c.subscribed[e.ID] = true
+	c.mux.Unlock()
+
+	// a failed reconcile is retried by the reconcile loop
+	c.reconcile(e)
 
 	return nil
 }
@@ -71,152 +186,144 @@ func (c *Cluster) RemoveEngine(e *citadel.Engine) error {
 	c.mux.Lock()
 	defer c.mux.Unlock()
 
+	if err := c.registry.DeleteEngine(e); err != nil {
+		return err
+	}
+
 	delete(c.engines, e.ID)
+	c.ledger.removeEngine(e.ID)
 
 	return nil
 }
 
 // ListContainers returns all the containers running in the cluster
 func (c *Cluster) ListContainers(all bool) []*citadel.Container {
-	out := []*citadel.Container{}
-	
+	var (
+		out     = []*citadel.Container{}
+		engines = c.Engines()
+	)
+
 	messages := make(chan []*citadel.Container, 1)
 	for _, e := range engines {
-		go func(engine *citadel.Engine){
+		go func(engine *citadel.Engine) {
 			containers, _ := engine.ListContainers(all)
 			messages <- containers
 		}(e)
 	}
+
 	for i := 0; i < len(engines); i++ {
 		containers := <-messages
 		out = append(out, containers...)
-        }
+	}
+
 	return out
 }
 
 func (c *Cluster) Kill(container *citadel.Container, sig int) error {
-	c.mux.Lock()
-	defer c.mux.Unlock()
-
-	engine := c.engines[container.Engine.ID]
-	if engine == nil {
-		return fmt.Errorf("engine with id %s is not in cluster", container.Engine.ID)
+	engine, err := c.engine(container.Engine.ID)
+	if err != nil {
+		return err
 	}
 
 	return engine.Kill(container, sig)
 }
 
 func (c *Cluster) Logs(container *citadel.Container, stdout bool, stderr bool) (io.ReadCloser, error) {
-	engine := c.engines[container.Engine.ID]
-	if engine == nil {
-		return nil, fmt.Errorf("engine with id %s is not in cluster", container.Engine.ID)
+	engine, err := c.engine(container.Engine.ID)
+	if err != nil {
+		return nil, err
 	}
 
 	return engine.Logs(container, stdout, stderr)
 }
 
 func (c *Cluster) Stop(container *citadel.Container) error {
-	c.mux.Lock()
-	defer c.mux.Unlock()
-
-	engine := c.engines[container.Engine.ID]
-	if engine == nil {
-		return fmt.Errorf("engine with id %s is not in cluster", container.Engine.ID)
+	engine, err := c.engine(container.Engine.ID)
+	if err != nil {
+		return err
 	}
 
 	return engine.Stop(container)
 }
 
 func (c *Cluster) Restart(container *citadel.Container, timeout int) error {
-	c.mux.Lock()
-	defer c.mux.Unlock()
-
-	engine := c.engines[container.Engine.ID]
-	if engine == nil {
-		return fmt.Errorf("engine with id %s is not in cluster", container.Engine.ID)
+	engine, err := c.engine(container.Engine.ID)
+	if err != nil {
+		return err
 	}
 
 	return engine.Restart(container, timeout)
 }
 
 func (c *Cluster) Remove(container *citadel.Container) error {
-	c.mux.Lock()
-	defer c.mux.Unlock()
+	engine, err := c.engine(container.Engine.ID)
+	if err != nil {
+		return err
+	}
 
-	engine := c.engines[container.Engine.ID]
-	if engine == nil {
-		return fmt.Errorf("engine with id %s is not in cluster", container.Engine.ID)
+	if err := engine.Remove(container); err != nil {
+		return err
 	}
 
-	return engine.Remove(container)
+	return c.ledger.remove(engine.ID, container.ID)
 }
 
+// Start places the image on an engine of the cluster and runs it.  Placement decisions
+// are made without holding the cluster lock and are committed against the versioned
+// engine state, retrying with fresh state when another placement won the race.
 func (c *Cluster) Start(image *citadel.Image, pull bool) (*citadel.Container, error) {
-	c.mux.Lock()
-	defer c.mux.Unlock()
+	p, err := c.prepare(image)
+	if err != nil {
+		return nil, err
+	}
 
-	var (
-		accepted  = []*citadel.EngineSnapshot{}
-		scheduler = c.schedulers[image.Type]
-	)
+	if err := c.place([]*placement{p}); err != nil {
+		return nil, err
+	}
 
-	if scheduler == nil {
-		return nil, fmt.Errorf("no scheduler for type %s", image.Type)
+	if err := c.run(p.container, p.reservation, pull); err != nil {
+		return nil, err
 	}
 
-	for _, e := range c.engines {
-		canrun, err := scheduler.Schedule(image, e)
-		if err != nil {
-			return nil, err
-		}
+	return p.container, nil
+}
 
-		if canrun {
-			containers, err := e.ListContainers(false)
-			if err != nil {
-				return nil, err
-			}
-
-			var cpus, memory float64
-			for _, con := range containers {
-				cpus += con.Image.Cpus
-				memory += con.Image.Memory
-			}
-
-			accepted = append(accepted, &citadel.EngineSnapshot{
-				ID:             e.ID,
-				ReservedCpus:   cpus,
-				ReservedMemory: memory,
-				Cpus:           e.Cpus,
-				Memory:         e.Memory,
-			})
-		}
-	}
+// run starts the container on the engine of the committed reservation and binds the
+// reservation to the container
+func (c *Cluster) run(container *citadel.Container, r *citadel.Reservation, pull bool) error {
+	engine, err := c.engine(r.EngineID)
+	if err != nil {
+		c.ledger.release(r)
 
-	if len(accepted) == 0 {
-		return nil, fmt.Errorf("no eligible engines to run image")
+		return err
 	}
 
-	container := &citadel.Container{
-		Image: image,
-		Name:  image.ContainerName,
-	}
+	if err := engine.Start(container, pull); err != nil {
+		c.ledger.release(r)
 
-	s, err := c.resourceManager.PlaceContainer(container, accepted)
-	if err != nil {
-		return nil, err
+		return err
 	}
 
-	engine := c.engines[s.ID]
+	if err := c.ledger.bind(r, container); err != nil {
+		// do not leave a container running that the cluster has no record of
+		engine.Remove(container)
+		c.ledger.remove(engine.ID, container.ID)
 
-	if err := engine.Start(container, pull); err != nil {
-		return nil, err
+		return err
 	}
 
-	return container, nil
+	return nil
 }
 
 // Engines returns the engines registered in the cluster
 func (c *Cluster) Engines() []*citadel.Engine {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.listEngines()
+}
+
+func (c *Cluster) listEngines() []*citadel.Engine {
 	out := []*citadel.Engine{}
 
 	for _, e := range c.engines {
@@ -226,48 +333,76 @@ func (c *Cluster) Engines() []*citadel.Engine {
 	return out
 }
 
+func (c *Cluster) engine(id string) (*citadel.Engine, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	engine := c.engines[id]
+	if engine == nil {
+		return nil, fmt.Errorf("engine with id %s is not in cluster", id)
+	}
+
+	return engine, nil
+}
+
 // Info returns information about the cluster
 func (c *Cluster) ClusterInfo() *citadel.ClusterInfo {
+	engines := c.Engines()
 	containerCount := 0
 	imageCount := 0
-	engineCount := len(c.engines)
+	engineCount := len(engines)
 	totalCpu := 0.0
 	totalMemory := 0.0
+	totalDisk := 0.0
 	reservedCpus := 0.0
 	reservedMemory := 0.0
-	for _, e := range c.engines {
-		c, err := e.ListContainers(false)
-		if err != nil {
-			// skip engines that are not available
-			continue
-		}
-		for _, cnt := range c {
-			reservedCpus += cnt.Image.Cpus
-			reservedMemory += cnt.Image.Memory
-		}
+	reservedDisk := 0.0
+	for _, e := range engines {
 		i, err := e.ListImages()
 		if err != nil {
 			// skip engines that are not available
 			continue
 		}
-		containerCount += len(c)
+		reserved := c.ledger.reserved(e.ID)
+		reservedCpus += reserved.cpus
+		reservedMemory += reserved.memory
+		reservedDisk += reserved.disk
+		containerCount += reserved.containers
 		imageCount += len(i)
 		totalCpu += e.Cpus
 		totalMemory += e.Memory
+		totalDisk += e.Disk
 	}
 
 	return &citadel.ClusterInfo{
 		Cpus:           totalCpu,
 		Memory:         totalMemory,
+		Disk:           totalDisk,
 		ContainerCount: containerCount,
 		ImageCount:     imageCount,
 		EngineCount:    engineCount,
 		ReservedCpus:   reservedCpus,
 		ReservedMemory: reservedMemory,
+		ReservedDisk:   reservedDisk,
+		Tenants:        c.ledger.tenants(),
 	}
 }
 
+// SetQuota sets the quota of the tenant enforced when containers are started.  A nil
+// quota removes the tenant's quota.
+func (c *Cluster) SetQuota(tenant string, q *citadel.Quota) {
+	c.ledger.setQuota(tenant, q)
+}
+
+// Tenants returns the resources reserved by the containers of each tenant along with
+// the tenant's quota
+func (c *Cluster) Tenants() map[string]*citadel.TenantUsage {
+	return c.ledger.tenants()
+}
+
 // Close signals to the cluster that no other actions will be applied
 func (c *Cluster) Close() error {
+	close(c.done)
+
 	return nil
 }
@@ -0,0 +1,86 @@
+package cluster
+
+import (
+	"time"
+
+	"github.com/citadel/citadel"
+)
+
+// reconcileInterval is how often the ledger is compared with the containers
+// that docker reports as running
+const reconcileInterval = 30 * time.Second
+
+// engineEvents receives the events of every engine in the cluster, keeping the ledger
+// up to date before passing the event to the handlers registered with the cluster
+type engineEvents struct {
+	c *Cluster
+}
+
+func (h *engineEvents) Handle(e *citadel.Event) error {
+	c := h.c
+
+	if e.Container != nil {
+		switch e.Type {
+		case "start":
+			if e.Container.State == "running" {
+				c.ledger.track(e.Container)
+			}
+		case "die", "destroy":
+			c.ledger.remove(e.Engine.ID, e.Container.ID)
+			c.convergeServices()
+			c.processQueue()
+
+			go c.jobExited(e.Engine, e.Container.ID)
+		}
+	}
+
+	return c.publish(e)
+}
+
+// publish passes the event to the handlers registered with the cluster
+func (c *Cluster) publish(e *citadel.Event) error {
+	c.mux.Lock()
+	handlers := append([]citadel.EventHandler{}, c.handlers...)
+	c.mux.Unlock()
+
+	for _, handler := range handlers {
+		if err := handler.Handle(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reconcileLoop periodically corrects the ledger with the state reported by docker
+// for containers that were started, stopped or removed outside of the cluster
+func (c *Cluster) reconcileLoop() {
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			for _, e := range c.Engines() {
+				if e.IsConnected() {
+					c.reconcile(e)
+				}
+			}
+		}
+	}
+}
+
+func (c *Cluster) reconcile(e *citadel.Engine) error {
+	version := c.ledger.version(e.ID)
+
+	containers, err := e.ListContainers(false)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ledger.reconcile(e.ID, version, containers)
+
+	return err
+}
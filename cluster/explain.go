@@ -0,0 +1,168 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/citadel/citadel"
+)
+
+// Explain places the image like Start without reserving resources or starting a container.
+// It reports the decision of every scheduler for each engine, the resource scores of each
+// engine and the engine that the image would be placed on.
+func (c *Cluster) Explain(image *citadel.Image) (*citadel.Explanation, error) {
+	c.mux.Lock()
+	scheduler := c.schedulers[image.Type]
+	engines := c.listEngines()
+	c.mux.Unlock()
+
+	if scheduler == nil {
+		return nil, fmt.Errorf("no scheduler for type %s", image.Type)
+	}
+
+	sort.Sort(enginesByID(engines))
+
+	var (
+		x         = &citadel.Explanation{Image: image}
+		accepted  = []*citadel.Engine{}
+		snapshots = []*citadel.EngineSnapshot{}
+		explained = make(map[string]*citadel.EngineExplanation)
+	)
+
+	for _, e := range engines {
+		ex, err := explainEngine(scheduler, image, e)
+		if err != nil {
+			return nil, err
+		}
+
+		snapshot := c.ledger.snapshot(e, image)
+
+		// host ports are checked against the ports held on the engine like in plan
+		if len(image.BindPorts) > 0 {
+			if _, err := citadel.AllocatePorts(image.BindPorts, snapshot.Ports, e.PortRange); err != nil {
+				ex.Accepted = false
+				ex.Decisions = append(ex.Decisions, citadel.Reject("port", err.Error()))
+			}
+		}
+
+		if ex.Accepted {
+			accepted = append(accepted, e)
+		}
+
+		x.Engines = append(x.Engines, ex)
+		explained[e.ID] = ex
+		snapshots = append(snapshots, snapshot)
+	}
+
+	preferences, err := c.preferences(scheduler, image, accepted)
+	if err != nil {
+		return nil, err
+	}
+
+	if scorer, ok := c.resourceManager.(citadel.ResourceScorer); ok {
+		for _, s := range scorer.Scores(&citadel.Container{Image: image}, snapshots) {
+			explained[s.Engine].Resources = s
+		}
+	}
+
+	candidates := []*citadel.EngineSnapshot{}
+	for _, s := range snapshots {
+		if explained[s.ID].Accepted {
+			s.Preference = preferences[s.ID]
+			explained[s.ID].Preference = s.Preference
+
+			candidates = append(candidates, s)
+		}
+	}
+
+	if err := c.ledger.admit([]*citadel.Reservation{c.reservation("", image)}); err != nil {
+		x.Error = err.Error()
+
+		return x, nil
+	}
+
+	if len(candidates) == 0 {
+		err := &citadel.UnschedulableError{Image: image, Decisions: make(map[string]*citadel.Decision)}
+
+		for _, ex := range x.Engines {
+			for _, d := range ex.Decisions {
+				if !d.Accepted {
+					err.Decisions[ex.Engine] = d
+
+					break
+				}
+			}
+		}
+
+		x.Error = err.Error()
+
+		return x, nil
+	}
+
+	container := &citadel.Container{Image: image}
+
+	chosen, err := c.resourceManager.PlaceContainer(container, candidates)
+	if preemptor, ok := c.resourceManager.(citadel.Preemptor); ok && err == citadel.ErrNoResources {
+		chosen, x.Preempted, err = preemptor.Preempt(container, candidates)
+	}
+
+	if err != nil {
+		x.Error = err.Error()
+
+		return x, nil
+	}
+
+	x.Chosen = chosen.ID
+
+	return x, nil
+}
+
+// explainEngine runs every scheduler that makes up the scheduler against the engine
+func explainEngine(scheduler citadel.Scheduler, image *citadel.Image, e *citadel.Engine) (*citadel.EngineExplanation, error) {
+	ex := &citadel.EngineExplanation{
+		Engine:   e.ID,
+		Health:   e.Health().State,
+		Accepted: true,
+	}
+
+	if ex.Health != citadel.Healthy {
+		ex.Accepted = false
+		ex.Decisions = append(ex.Decisions, citadel.Reject("health", "engine is %s", ex.Health))
+	}
+
+	for _, s := range flatten(scheduler) {
+		d, err := s.Schedule(image, e)
+		if err != nil {
+			return nil, err
+		}
+
+		if !d.Accepted {
+			ex.Accepted = false
+		}
+
+		ex.Decisions = append(ex.Decisions, d)
+	}
+
+	return ex, nil
+}
+
+// flatten returns the schedulers that make up the scheduler
+func flatten(s citadel.Scheduler) []citadel.Scheduler {
+	group, ok := s.(citadel.SchedulerGroup)
+	if !ok {
+		return []citadel.Scheduler{s}
+	}
+
+	out := []citadel.Scheduler{}
+	for _, child := range group.Schedulers() {
+		out = append(out, flatten(child)...)
+	}
+
+	return out
+}
+
+type enginesByID []*citadel.Engine
+
+func (b enginesByID) Len() int           { return len(b) }
+func (b enginesByID) Less(i, j int) bool { return b[i].ID < b[j].ID }
+func (b enginesByID) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
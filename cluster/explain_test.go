@@ -0,0 +1,67 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/citadel/citadel"
+	"github.com/citadel/citadel/registry"
+	"github.com/citadel/citadel/scheduler"
+	"github.com/samalba/dockerclient"
+)
+
+func TestExplain(t *testing.T) {
+	client, err := dockerclient.NewDockerClient("http://127.0.0.1:2375", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		east  = &citadel.Engine{ID: "east", Cpus: 4, Memory: 4096, Labels: []string{"zone=east"}}
+		west  = &citadel.Engine{ID: "west", Cpus: 4, Memory: 4096, Labels: []string{"zone=west"}}
+		image = &citadel.Image{Name: "redis", Type: "service", Cpus: 1, Memory: 512, Labels: []string{"zone==east"}}
+		c     = &Cluster{
+			engines:         map[string]*citadel.Engine{"east": east, "west": west},
+			schedulers:      map[string]citadel.Scheduler{"service": scheduler.NewMultiScheduler(&scheduler.LabelScheduler{})},
+			resourceManager: scheduler.NewResourceManager(),
+			ledger:          newLedger(registry.NewMemoryRegistry()),
+		}
+	)
+
+	for _, e := range []*citadel.Engine{east, west} {
+		e.SetClient(client)
+		c.ledger.addEngine(e.ID)
+	}
+
+	x, err := c.Explain(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if x.Chosen != "east" || x.Error != "" {
+		t.Fatalf("expected the image to be placed on east; received %q %q", x.Chosen, x.Error)
+	}
+
+	if len(x.Engines) != 2 {
+		t.Fatalf("expected an explanation for both engines; received %d", len(x.Engines))
+	}
+
+	rejected := x.Engines[1]
+	if rejected.Engine != "west" || rejected.Accepted || len(rejected.Decisions) != 1 || rejected.Decisions[0].Scheduler != "label" {
+		t.Fatalf("expected west to be rejected by the label scheduler; received %+v", rejected)
+	}
+
+	if r := x.Engines[0].Resources; r == nil || !r.Fits || r.Cpus != 25 {
+		t.Fatalf("expected the image to use 25%% of the cpus of east; received %+v", r)
+	}
+
+	_, err = c.prepare(&citadel.Image{Name: "redis", Type: "service", Labels: []string{"zone==north"}})
+
+	unschedulable, ok := err.(*citadel.UnschedulableError)
+	if !ok {
+		t.Fatalf("expected an unschedulable error; received %v", err)
+	}
+
+	if d := unschedulable.Decisions["east"]; d == nil || d.Scheduler != "label" || d.Reason != `engine does not match "zone==north"` {
+		t.Fatalf("expected east to be rejected by the label scheduler; received %v", d)
+	}
+}
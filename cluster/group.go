@@ -0,0 +1,72 @@
+package cluster
+
+import (
+	"fmt"
+
+	"github.com/citadel/citadel"
+)
+
+// StartGroup places count replicas of the image on the cluster as one operation.  All the
+// replicas are placed against the same view of the engines and either all of them are
+// started or none are left running.  If strategy is not empty it overrides the strategy
+// of the image.  Images with an image!= affinity to themselves or whose type's scheduler
+// only runs one container of an image per engine get one replica per engine, including
+// the replicas started or being started by other groups.
+func (c *Cluster) StartGroup(image *citadel.Image, count int, strategy string, pull bool) ([]*citadel.Container, error) {
+	if count < 1 {
+		return nil, fmt.Errorf("invalid replica count %d", count)
+	}
+
+	if strategy != "" {
+		copied := *image
+		copied.Strategy = strategy
+		image = &copied
+	}
+
+	replicas := []*citadel.Image{}
+	for i := 0; i < count; i++ {
+		replica := *image
+		if image.ContainerName != "" {
+			replica.ContainerName = fmt.Sprintf("%s-%d", image.ContainerName, i)
+		}
+
+		replicas = append(replicas, &replica)
+	}
+
+	return c.StartGang(replicas, pull)
+}
+
+// StartGang starts the images as a gang, either all of them or none.  Each image is checked
+// by the scheduler for its type and capacity for the whole gang is reserved at once before
+// any container is started.  If any container fails to start the containers of the gang
+// that did start are removed.  The containers are returned in the order of the images.
+func (c *Cluster) StartGang(images []*citadel.Image, pull bool) ([]*citadel.Container, error) {
+	if len(images) == 0 {
+		return nil, fmt.Errorf("gang has no images")
+	}
+
+	placements := []*placement{}
+	for _, image := range images {
+		p, err := c.prepare(image)
+		if err != nil {
+			return nil, err
+		}
+
+		placements = append(placements, p)
+	}
+
+	if err := c.place(placements); err != nil {
+		return nil, err
+	}
+
+	if err := c.startAll(placements, pull); err != nil {
+		return nil, err
+	}
+
+	out := []*citadel.Container{}
+	for _, p := range placements {
+		out = append(out, p.container)
+	}
+
+	return out, nil
+}
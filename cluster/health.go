@@ -0,0 +1,66 @@
+package cluster
+
+import (
+	"sync"
+	"time"
+
+	"github.com/citadel/citadel"
+)
+
+// healthInterval is how often the engines of the cluster are pinged
+const healthInterval = 5 * time.Second
+
+// healthLoop periodically checks the health of every engine, publishing an event
+// when the health state of an engine changes
+func (c *Cluster) healthLoop() {
+	ticker := time.NewTicker(healthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.checkHealth()
+		}
+	}
+}
+
+func (c *Cluster) checkHealth() {
+	var wg sync.WaitGroup
+
+	for _, e := range c.Engines() {
+		wg.Add(1)
+
+		go func(e *citadel.Engine) {
+			defer wg.Done()
+
+			h, changed := e.CheckHealth()
+			if !changed {
+				if h.State == citadel.Unreachable && c.unmovedContainers(e) {
+					go c.reschedule(e)
+				}
+
+				return
+			}
+
+			if h.State != citadel.Unreachable {
+				c.removeMoved(e)
+				c.connect(e)
+				c.processQueue()
+			}
+
+			c.publish(&citadel.Event{
+				Type:   citadel.HealthEventType(h.State),
+				Engine: e,
+				Time:   h.Checked,
+			})
+
+			if h.State == citadel.Unreachable {
+				go c.reschedule(e)
+			}
+		}(e)
+	}
+
+	wg.Wait()
+}
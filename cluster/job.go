@@ -0,0 +1,249 @@
+package cluster
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/citadel/citadel"
+)
+
+// jobInterval is how often the containers of running jobs are inspected in case their
+// die event was missed and finished jobs are checked for cleanup
+const jobInterval = 10 * time.Second
+
+// JobRetention is how long the containers and the status of a finished job are kept
+var JobRetention = 1 * time.Hour
+
+// RunJob starts a container of the image that is expected to run to completion.  If the
+// container exits with a code other than 0 it is started again up to retries times.  An
+// error is returned if the first container of the job cannot be started.
+func (c *Cluster) RunJob(image *citadel.Image, retries int) (*citadel.Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	j := &citadel.Job{
+		ID:      id,
+		Image:   image,
+		Retries: retries,
+		State:   citadel.JobRunning,
+		Started: time.Now(),
+	}
+
+	if err := c.attempt(j); err != nil {
+		return nil, err
+	}
+
+	return c.Job(id)
+}
+
+// Job returns the status of the job
+func (c *Cluster) Job(id string) (*citadel.Job, error) {
+	c.jobMux.Lock()
+	defer c.jobMux.Unlock()
+
+	j := c.jobs[id]
+	if j == nil {
+		return nil, fmt.Errorf("job %s does not exist", id)
+	}
+
+	return copyJob(j), nil
+}
+
+// Jobs returns the status of the jobs that are running or finished within the retention
+func (c *Cluster) Jobs() []*citadel.Job {
+	c.jobMux.Lock()
+	defer c.jobMux.Unlock()
+
+	out := []*citadel.Job{}
+	for _, j := range c.jobs {
+		out = append(out, copyJob(j))
+	}
+
+	return out
+}
+
+// attempt starts a new container for the job
+func (c *Cluster) attempt(j *citadel.Job) error {
+	image := *j.Image
+
+	container, err := c.Start(&image, false)
+	if err != nil {
+		return err
+	}
+
+	c.jobMux.Lock()
+	j.Attempts = append(j.Attempts, &citadel.JobAttempt{
+		ContainerID: container.ID,
+		EngineID:    container.Engine.ID,
+		Started:     time.Now(),
+	})
+	c.jobs[j.ID] = j
+	c.jobContainers[container.ID] = j
+	c.jobMux.Unlock()
+
+	return nil
+}
+
+// jobExited records the exit code of the job's container if it is no longer running and
+// retries the job if it failed
+func (c *Cluster) jobExited(e *citadel.Engine, containerID string) {
+	c.jobMux.Lock()
+	j, a := c.runningAttempt(containerID)
+	c.jobMux.Unlock()
+
+	if a == nil {
+		return
+	}
+
+	container, err := citadel.FromDockerContainer(containerID, j.Image.Name, e)
+	if err == nil && container.State == "running" {
+		return
+	}
+
+	c.jobMux.Lock()
+	// the attempt may have been finished while the container was inspected
+	if _, current := c.runningAttempt(containerID); current != a {
+		c.jobMux.Unlock()
+
+		return
+	}
+
+	now := time.Now()
+	a.Finished = now
+
+	switch err {
+	case nil:
+		a.ExitCode = container.ExitCode
+	default:
+		// the container was removed before it could be inspected
+		a.ExitCode = -1
+		a.Error = err.Error()
+	}
+
+	j.ExitCode = a.ExitCode
+
+	retry := false
+	switch {
+	case a.ExitCode == 0:
+		j.State = citadel.JobSucceeded
+		j.Finished = now
+	case len(j.Attempts) <= j.Retries:
+		retry = true
+	default:
+		j.State = citadel.JobFailed
+		j.Finished = now
+	}
+	c.jobMux.Unlock()
+
+	if retry {
+		go c.retry(j)
+	}
+}
+
+func (c *Cluster) retry(j *citadel.Job) {
+	if err := c.attempt(j); err != nil {
+		c.jobMux.Lock()
+		j.State = citadel.JobFailed
+		j.Error = err.Error()
+		j.Finished = time.Now()
+		c.jobMux.Unlock()
+	}
+}
+
+// runningAttempt returns the job and its unfinished attempt for the container.  The job
+// lock must be held.
+func (c *Cluster) runningAttempt(containerID string) (*citadel.Job, *citadel.JobAttempt) {
+	j := c.jobContainers[containerID]
+	if j == nil || j.State != citadel.JobRunning || len(j.Attempts) == 0 {
+		return nil, nil
+	}
+
+	a := j.Attempts[len(j.Attempts)-1]
+	if a.ContainerID != containerID || !a.Finished.IsZero() {
+		return nil, nil
+	}
+
+	return j, a
+}
+
+// jobLoop checks the containers of running jobs and removes finished jobs after the retention
+func (c *Cluster) jobLoop() {
+	ticker := time.NewTicker(jobInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.checkJobs()
+		}
+	}
+}
+
+func (c *Cluster) checkJobs() {
+	var (
+		running = []*citadel.JobAttempt{}
+		expired = []*citadel.Job{}
+	)
+
+	c.jobMux.Lock()
+	for id, j := range c.jobs {
+		switch {
+		case j.State == citadel.JobRunning:
+			if a := j.Attempts[len(j.Attempts)-1]; a.Finished.IsZero() {
+				running = append(running, a)
+			}
+		case time.Since(j.Finished) > JobRetention:
+			expired = append(expired, j)
+
+			delete(c.jobs, id)
+			for _, a := range j.Attempts {
+				delete(c.jobContainers, a.ContainerID)
+			}
+		}
+	}
+	c.jobMux.Unlock()
+
+	for _, a := range running {
+		if e, err := c.engine(a.EngineID); err == nil && e.IsConnected() {
+			c.jobExited(e, a.ContainerID)
+		}
+	}
+
+	for _, j := range expired {
+		for _, a := range j.Attempts {
+			e, err := c.engine(a.EngineID)
+			if err != nil || !e.IsConnected() {
+				continue
+			}
+
+			c.Remove(&citadel.Container{ID: a.ContainerID, Engine: e})
+		}
+	}
+}
+
+func copyJob(j *citadel.Job) *citadel.Job {
+	out := *j
+	out.Attempts = []*citadel.JobAttempt{}
+
+	for _, a := range j.Attempts {
+		copied := *a
+		out.Attempts = append(out.Attempts, &copied)
+	}
+
+	return &out
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
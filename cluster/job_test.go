@@ -0,0 +1,55 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/citadel/citadel"
+)
+
+func TestCheckJobsRetention(t *testing.T) {
+	c := &Cluster{
+		engines:       make(map[string]*citadel.Engine),
+		jobs:          make(map[string]*citadel.Job),
+		jobContainers: make(map[string]*citadel.Job),
+	}
+
+	var (
+		expired = &citadel.Job{
+			ID:       "expired",
+			State:    citadel.JobSucceeded,
+			Finished: time.Now().Add(-2 * JobRetention),
+			Attempts: []*citadel.JobAttempt{{ContainerID: "a", EngineID: "e1"}},
+		}
+		recent = &citadel.Job{
+			ID:       "recent",
+			State:    citadel.JobFailed,
+			Finished: time.Now(),
+			Attempts: []*citadel.JobAttempt{{ContainerID: "b", EngineID: "e1"}},
+		}
+	)
+
+	for _, j := range []*citadel.Job{expired, recent} {
+		c.jobs[j.ID] = j
+		c.jobContainers[j.Attempts[0].ContainerID] = j
+	}
+
+	c.checkJobs()
+
+	if _, err := c.Job("expired"); err == nil {
+		t.Fatalf("expected job expired to be removed after the retention")
+	}
+
+	if _, ok := c.jobContainers["a"]; ok {
+		t.Fatalf("expected the containers of job expired to be forgotten")
+	}
+
+	j, err := c.Job("recent")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if j.State != citadel.JobFailed {
+		t.Fatalf("expected job recent to be failed; received %s", j.State)
+	}
+}
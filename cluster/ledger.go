@@ -0,0 +1,598 @@
+package cluster
+
+import (
+	"sync"
+	"time"
+
+	"github.com/citadel/citadel"
+)
+
+// ledger keeps the reservations held on each engine of the cluster so that engine
+// snapshots can be built without asking docker.  Every change to an engine's
+// reservations bumps the version of that engine so that placements decided against
+// stale state are rejected on commit.  Changes are saved in the registry after the
+// accounts are updated and the lock is released, so that snapshots never wait on the
+// registry.
+type ledger struct {
+	mux sync.Mutex
+
+	// seq is the sequence number of the next change to the accounts
+	seq uint64
+
+	// persisted is the sequence number of the next change to write to the registry.  The
+	// writes wait on persistCond for their turn so they are made in the order of the changes.
+	persistMux  sync.Mutex
+	persistCond *sync.Cond
+	persisted   uint64
+
+	registry citadel.Registry
+	accounts map[string]*account
+
+	// quotas are the quotas of each tenant
+	quotas map[string]*citadel.Quota
+}
+
+// totals are the resources reserved on an engine
+type totals struct {
+	cpus       float64
+	memory     float64
+	disk       float64
+	containers int
+}
+
+// account is the reservations held on a single engine
+type account struct {
+	totals
+
+	version uint64
+
+	// running are reservations for containers known to docker by id
+	running map[string]*citadel.Reservation
+
+	// pending are reservations committed for containers that are being started
+	pending []*citadel.Reservation
+
+	// images is the number of reservations for each image
+	images map[string]int
+}
+
+func newLedger(registry citadel.Registry) *ledger {
+	l := &ledger{
+		registry: registry,
+		accounts: make(map[string]*account),
+		quotas:   make(map[string]*citadel.Quota),
+	}
+	l.persistCond = sync.NewCond(&l.persistMux)
+
+	return l
+}
+
+func (t *totals) add(r *citadel.Reservation) {
+	t.cpus += r.Cpus
+	t.memory += r.Memory
+	t.disk += r.Disk
+}
+
+func (t *totals) sub(r *citadel.Reservation) {
+	t.cpus -= r.Cpus
+	t.memory -= r.Memory
+	t.disk -= r.Disk
+}
+
+func (a *account) add(r *citadel.Reservation) {
+	a.totals.add(r)
+
+	if r.Image != nil {
+		a.images[r.Image.Key()]++
+	}
+}
+
+func (a *account) sub(r *citadel.Reservation) {
+	a.totals.sub(r)
+
+	if r.Image != nil {
+		a.images[r.Image.Key()]--
+	}
+}
+
+func (l *ledger) addEngine(id string) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if l.accounts[id] == nil {
+		l.accounts[id] = &account{
+			running: make(map[string]*citadel.Reservation),
+			images:  make(map[string]int),
+		}
+	}
+}
+
+func (l *ledger) removeEngine(id string) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	delete(l.accounts, id)
+}
+
+// load restores reservations saved in the registry for engines in the ledger
+func (l *ledger) load() error {
+	reservations, err := l.registry.FetchReservations()
+	if err != nil {
+		return err
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	for _, r := range reservations {
+		a := l.accounts[r.EngineID]
+		if a == nil || a.running[r.ContainerID] != nil {
+			continue
+		}
+
+		a.running[r.ContainerID] = r
+		a.add(r)
+	}
+
+	return nil
+}
+
+// snapshot returns the reserved resources of the engine and the number of replicas of
+// the image on the engine without waiting on docker or the registry
+func (l *ledger) snapshot(e *citadel.Engine, image *citadel.Image) *citadel.EngineSnapshot {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	s := &citadel.EngineSnapshot{
+		ID:     e.ID,
+		Labels: e.Labels,
+		Cpus:   e.Cpus,
+		Memory: e.Memory,
+		Disk:   e.Disk,
+	}
+
+	if a := l.accounts[e.ID]; a != nil {
+		s.Version = a.version
+		s.ReservedCpus = a.cpus
+		s.ReservedMemory = a.memory
+		s.ReservedDisk = a.disk
+		s.Replicas = a.images[image.Key()]
+
+		for _, r := range a.running {
+			s.Reservations = append(s.Reservations, r)
+		}
+
+		for _, r := range a.pending {
+			s.PendingCpus += r.Cpus
+			s.PendingMemory += r.Memory
+		}
+
+		s.Ports = a.ports()
+	}
+
+	if u := e.Usage(); u != nil {
+		s.CurrentCpu = u.Cpus
+		s.CurrentMemory = u.Memory
+	}
+
+	return s
+}
+
+// version returns the current version of the engine's reservations
+func (l *ledger) version(id string) uint64 {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if a := l.accounts[id]; a != nil {
+		return a.version
+	}
+
+	return 0
+}
+
+// reserve atomically accepts the reservations if none of the engines have changed since
+// the versions were read.  ErrConflict is returned when any of the versions is stale and
+// the placements must be evaluated again.
+func (l *ledger) reserve(versions map[string]uint64, reservations []*citadel.Reservation) error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	for id, version := range versions {
+		if a := l.accounts[id]; a == nil || a.version != version {
+			return ErrConflict
+		}
+	}
+
+	if err := l.checkQuotas(reservations); err != nil {
+		return err
+	}
+
+	for _, r := range reservations {
+		a := l.accounts[r.EngineID]
+		if a == nil {
+			return ErrConflict
+		}
+	}
+
+	for _, r := range reservations {
+		a := l.accounts[r.EngineID]
+
+		a.version++
+		a.pending = append(a.pending, r)
+		a.add(r)
+	}
+
+	return nil
+}
+
+// bind moves the pending reservation to the running container and saves it in the registry.
+// The host ports that docker allocated for the container are recorded on the reservation.
+func (l *ledger) bind(r *citadel.Reservation, c *citadel.Container) error {
+	l.mux.Lock()
+
+	a := l.accounts[r.EngineID]
+	if a == nil {
+		l.mux.Unlock()
+
+		return nil
+	}
+
+	a.version++
+	if a.removePending(r) {
+		a.sub(r)
+	}
+
+	// the start event for the container may have been handled before the bind
+	if existing := a.running[c.ID]; existing != nil {
+		a.sub(existing)
+	}
+
+	if len(c.Ports) > 0 {
+		r.Ports = c.Ports
+	}
+
+	r.ContainerID = c.ID
+	a.running[c.ID] = r
+	a.add(r)
+
+	return l.persist([]*citadel.Reservation{r}, nil)
+}
+
+// release drops a pending reservation for a container that failed to start
+func (l *ledger) release(r *citadel.Reservation) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	a := l.accounts[r.EngineID]
+	if a == nil {
+		return
+	}
+
+	a.version++
+	if a.removePending(r) {
+		a.sub(r)
+	}
+}
+
+// track adds a running container that the ledger does not know about yet
+func (l *ledger) track(c *citadel.Container) error {
+	l.mux.Lock()
+
+	a := l.accounts[c.Engine.ID]
+	if a == nil || a.running[c.ID] != nil {
+		l.mux.Unlock()
+
+		return nil
+	}
+
+	r := reservationFor(c)
+
+	a.version++
+	a.running[c.ID] = r
+	a.add(r)
+
+	return l.persist([]*citadel.Reservation{r}, nil)
+}
+
+// remove drops the reservation held by the container on the engine
+func (l *ledger) remove(engineID, containerID string) error {
+	l.mux.Lock()
+
+	a := l.accounts[engineID]
+	if a == nil || a.running[containerID] == nil {
+		l.mux.Unlock()
+
+		return nil
+	}
+
+	r := a.running[containerID]
+
+	a.version++
+	delete(a.running, containerID)
+	a.sub(r)
+
+	return l.persist(nil, []*citadel.Reservation{r})
+}
+
+// reconcile replaces the running reservations of the engine with the containers docker
+// reports as running.  Reservations for containers that the ledger already knows keep
+// their original values.  The reconcile is skipped if the engine changed since version
+// was read, because the containers may no longer reflect the state of the ledger.  The
+// registry is updated once the accounts are swapped.
+func (l *ledger) reconcile(engineID string, version uint64, containers []*citadel.Container) (bool, error) {
+	l.mux.Lock()
+
+	a := l.accounts[engineID]
+	if a == nil || a.version != version {
+		l.mux.Unlock()
+
+		return false, nil
+	}
+
+	var (
+		running = make(map[string]*citadel.Reservation)
+		added   = []*citadel.Reservation{}
+		removed = []*citadel.Reservation{}
+	)
+
+	for _, c := range containers {
+		r := a.running[c.ID]
+		if r == nil {
+			r = reservationFor(c)
+			added = append(added, r)
+		}
+
+		running[c.ID] = r
+	}
+
+	for id, r := range a.running {
+		if running[id] == nil {
+			removed = append(removed, r)
+		}
+	}
+
+	a.version++
+	a.running = running
+	a.totals = totals{}
+	a.images = make(map[string]int)
+
+	for _, r := range a.running {
+		a.add(r)
+	}
+
+	for _, r := range a.pending {
+		a.add(r)
+	}
+
+	return true, l.persist(added, removed)
+}
+
+// persist saves and deletes the reservations in the registry.  It must be called with
+// the ledger lock held and releases it before waiting for the writes of earlier changes,
+// so that the ledger is never locked while the registry is written.  Every write is
+// attempted and the first error is returned.
+func (l *ledger) persist(saved, deleted []*citadel.Reservation) error {
+	seq := l.seq
+	l.seq++
+	l.mux.Unlock()
+
+	l.persistMux.Lock()
+	defer l.persistMux.Unlock()
+
+	for l.persisted != seq {
+		l.persistCond.Wait()
+	}
+
+	var err error
+
+	for _, r := range saved {
+		if e := l.registry.SaveReservation(r); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	for _, r := range deleted {
+		if e := l.registry.DeleteReservation(r); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	l.persisted++
+	l.persistCond.Broadcast()
+
+	return err
+}
+
+// running returns the reservations of the containers running on the engine
+func (l *ledger) running(id string) []*citadel.Reservation {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	out := []*citadel.Reservation{}
+
+	if a := l.accounts[id]; a != nil {
+		for _, r := range a.running {
+			out = append(out, r)
+		}
+	}
+
+	return out
+}
+
+// setQuota sets the quota of the tenant, removing it if q is nil
+func (l *ledger) setQuota(tenant string, q *citadel.Quota) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if q == nil {
+		delete(l.quotas, tenant)
+
+		return
+	}
+
+	l.quotas[tenant] = q
+}
+
+// admit returns a QuotaError if the reservations would exceed the quota of their tenant
+func (l *ledger) admit(reservations []*citadel.Reservation) error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	return l.checkQuotas(reservations)
+}
+
+// checkQuotas must be called with the ledger lock held
+func (l *ledger) checkQuotas(reservations []*citadel.Reservation) error {
+	usage := l.usage()
+
+	for _, r := range reservations {
+		tenant := tenantOf(r)
+		if tenant == "" || l.quotas[tenant] == nil {
+			continue
+		}
+
+		u := usage[tenant]
+		if u == nil {
+			u = &citadel.TenantUsage{}
+			usage[tenant] = u
+		}
+
+		u.Cpus += r.Cpus
+		u.Memory += r.Memory
+		u.Containers++
+
+		if err := l.quotas[tenant].Exceeded(tenant, u); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tenants returns the usage of every tenant that has reservations or a quota
+func (l *ledger) tenants() map[string]*citadel.TenantUsage {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	usage := l.usage()
+
+	for tenant, q := range l.quotas {
+		if usage[tenant] == nil {
+			usage[tenant] = &citadel.TenantUsage{}
+		}
+
+		copied := *q
+		usage[tenant].Quota = &copied
+	}
+
+	return usage
+}
+
+// usage sums the running and pending reservations by tenant.  The ledger lock must be held.
+func (l *ledger) usage() map[string]*citadel.TenantUsage {
+	usage := make(map[string]*citadel.TenantUsage)
+
+	add := func(r *citadel.Reservation) {
+		tenant := tenantOf(r)
+		if tenant == "" {
+			return
+		}
+
+		u := usage[tenant]
+		if u == nil {
+			u = &citadel.TenantUsage{}
+			usage[tenant] = u
+		}
+
+		u.Cpus += r.Cpus
+		u.Memory += r.Memory
+		u.Containers++
+	}
+
+	for _, a := range l.accounts {
+		for _, r := range a.running {
+			add(r)
+		}
+
+		for _, r := range a.pending {
+			add(r)
+		}
+	}
+
+	return usage
+}
+
+func tenantOf(r *citadel.Reservation) string {
+	if r.Image == nil {
+		return ""
+	}
+
+	return r.Image.Tenant
+}
+
+// reserved returns the reserved resources and number of running containers of the engine
+func (l *ledger) reserved(id string) totals {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	a := l.accounts[id]
+	if a == nil {
+		return totals{}
+	}
+
+	t := a.totals
+	t.containers = len(a.running)
+
+	return t
+}
+
+// ports returns the host ports held by the running and pending reservations
+func (a *account) ports() []*citadel.Port {
+	out := []*citadel.Port{}
+
+	for _, r := range a.running {
+		out = append(out, r.Ports...)
+	}
+
+	for _, r := range a.pending {
+		out = append(out, r.Ports...)
+	}
+
+	return out
+}
+
+func (a *account) removePending(r *citadel.Reservation) bool {
+	for i, p := range a.pending {
+		if p == r {
+			a.pending = append(a.pending[:i], a.pending[i+1:]...)
+
+			return true
+		}
+	}
+
+	return false
+}
+
+func newReservation(engineID string, image *citadel.Image) *citadel.Reservation {
+	return &citadel.Reservation{
+		EngineID: engineID,
+		Image:    image,
+		Cpus:     image.Cpus,
+		Memory:   image.Memory,
+		Disk:     image.Disk,
+		Time:     time.Now(),
+	}
+}
+
+func reservationFor(c *citadel.Container) *citadel.Reservation {
+	return &citadel.Reservation{
+		ContainerID: c.ID,
+		EngineID:    c.Engine.ID,
+		Image:       c.Image,
+		Cpus:        c.Image.Cpus,
+		Memory:      c.Image.Memory,
+		Disk:        c.Image.Disk,
+		Ports:       c.Ports,
+		Time:        time.Now(),
+	}
+}
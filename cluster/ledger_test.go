@@ -0,0 +1,184 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/citadel/citadel"
+	"github.com/citadel/citadel/registry"
+)
+
+func TestLedgerReserveConflict(t *testing.T) {
+	var (
+		l      = newLedger(registry.NewMemoryRegistry())
+		engine = &citadel.Engine{ID: "local", Cpus: 4, Memory: 2048}
+		image  = &citadel.Image{Name: "redis", Cpus: 1, Memory: 512}
+	)
+
+	l.addEngine(engine.ID)
+
+	first := l.snapshot(engine, image)
+	second := l.snapshot(engine, image)
+
+	if err := l.reserve(map[string]uint64{engine.ID: first.Version}, []*citadel.Reservation{newReservation(engine.ID, image)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.reserve(map[string]uint64{engine.ID: second.Version}, []*citadel.Reservation{newReservation(engine.ID, image)}); err != ErrConflict {
+		t.Fatalf("expected stale snapshot to conflict; received %v", err)
+	}
+
+	s := l.snapshot(engine, image)
+	if s.ReservedCpus != 1 || s.ReservedMemory != 512 {
+		t.Fatalf("expected pending reservation in snapshot; received cpus %f memory %f", s.ReservedCpus, s.ReservedMemory)
+	}
+}
+
+func TestLedgerBindAndRemove(t *testing.T) {
+	var (
+		l      = newLedger(registry.NewMemoryRegistry())
+		engine = &citadel.Engine{ID: "local", Cpus: 4, Memory: 2048}
+		image  = &citadel.Image{Name: "redis", Cpus: 1, Memory: 512}
+	)
+
+	l.addEngine(engine.ID)
+
+	r := newReservation(engine.ID, image)
+	if err := l.reserve(map[string]uint64{engine.ID: l.snapshot(engine, image).Version}, []*citadel.Reservation{r}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the start event arrives before the container is bound
+	if err := l.track(&citadel.Container{ID: "abc", Engine: engine, Image: image}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.bind(r, &citadel.Container{ID: "abc"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if r := l.reserved(engine.ID); r.cpus != 1 || r.containers != 1 {
+		t.Fatalf("expected one container with 1 cpu; received %d with %f", r.containers, r.cpus)
+	}
+
+	if err := l.remove(engine.ID, "abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	if r := l.reserved(engine.ID); r.cpus != 0 || r.memory != 0 || r.containers != 0 {
+		t.Fatalf("expected empty ledger; received %d containers cpus %f memory %f", r.containers, r.cpus, r.memory)
+	}
+}
+
+// blockingRegistry holds every save of a reservation until it is released
+type blockingRegistry struct {
+	citadel.Registry
+	release chan struct{}
+}
+
+func (r *blockingRegistry) SaveReservation(res *citadel.Reservation) error {
+	<-r.release
+
+	return r.Registry.SaveReservation(res)
+}
+
+func TestLedgerSnapshotDuringSave(t *testing.T) {
+	var (
+		reg    = &blockingRegistry{Registry: registry.NewMemoryRegistry(), release: make(chan struct{})}
+		l      = newLedger(reg)
+		engine = &citadel.Engine{ID: "local", Cpus: 4, Memory: 2048}
+		image  = &citadel.Image{Name: "redis", Cpus: 1, Memory: 512}
+		done   = make(chan error, 3)
+	)
+
+	l.addEngine(engine.ID)
+
+	// wait returns once the snapshot sees the cpus reserved, failing if it blocks or never does
+	wait := func(cpus float64) {
+		deadline := time.Now().Add(time.Second)
+
+		for l.snapshot(engine, image).ReservedCpus != cpus {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected the snapshot to see %f cpus while the registry is written", cpus)
+			}
+
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	for _, id := range []string{"abc", "def"} {
+		go func(id string) {
+			done <- l.track(&citadel.Container{ID: id, Engine: engine, Image: image})
+		}(id)
+	}
+
+	// both containers are tracked while their saves are held by the registry
+	wait(2)
+
+	go func() {
+		done <- l.remove(engine.ID, "abc")
+	}()
+
+	wait(1)
+
+	close(reg.release)
+
+	for i := 0; i < 3; i++ {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reservations, err := reg.FetchReservations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the delete of abc is written after its save
+	if len(reservations) != 1 || reservations[0].ContainerID != "def" {
+		t.Fatalf("expected only def to be saved; received %d reservations", len(reservations))
+	}
+}
+
+func TestLedgerQuota(t *testing.T) {
+	var (
+		l      = newLedger(registry.NewMemoryRegistry())
+		engine = &citadel.Engine{ID: "local", Cpus: 4, Memory: 2048}
+		image  = &citadel.Image{Name: "redis", Cpus: 1, Memory: 512, Tenant: "team-a"}
+		other  = &citadel.Image{Name: "redis", Cpus: 1, Memory: 512, Tenant: "team-b"}
+	)
+
+	l.addEngine(engine.ID)
+	l.setQuota("team-a", &citadel.Quota{Cpus: 2})
+
+	reserve := func(image *citadel.Image, count int) error {
+		reservations := []*citadel.Reservation{}
+		for i := 0; i < count; i++ {
+			reservations = append(reservations, newReservation(engine.ID, image))
+		}
+
+		return l.reserve(map[string]uint64{engine.ID: l.version(engine.ID)}, reservations)
+	}
+
+	if err := reserve(image, 3); err == nil {
+		t.Fatalf("expected a group over the quota to be rejected")
+	}
+
+	if err := reserve(image, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	err := reserve(image, 1)
+	if qe, ok := err.(*citadel.QuotaError); !ok || qe.Resource != "cpus" {
+		t.Fatalf("expected the cpus quota of team-a to be exceeded; received %v", err)
+	}
+
+	if err := reserve(other, 1); err != nil {
+		t.Fatalf("expected team-b to have no quota; received %v", err)
+	}
+
+	tenants := l.tenants()
+	if u := tenants["team-a"]; u == nil || u.Containers != 2 || u.Cpus != 2 || u.Quota == nil {
+		t.Fatalf("expected 2 containers using 2 cpus for team-a; received %v", u)
+	}
+}
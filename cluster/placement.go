@@ -0,0 +1,438 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/citadel/citadel"
+)
+
+// placement is a container to place on one of the engines accepted by the scheduler
+// for its type
+type placement struct {
+	container   *citadel.Container
+	engines     []*citadel.Engine
+	preferences map[string]float64
+
+	// exclusive placements do not share an engine with other containers of the same
+	// image placed at the same time
+	exclusive bool
+
+	// victims are the containers of a lower priority stopped to make room for the container
+	victims []*citadel.Reservation
+
+	reservation *citadel.Reservation
+}
+
+// planned are the resources of the placements decided on an engine but not yet committed
+type planned struct {
+	totals
+	images map[string]int
+	ports  []*citadel.Port
+}
+
+// prepare runs the scheduler for the image's type against the healthy engines of
+// the cluster and scores the accepted engines
+func (c *Cluster) prepare(image *citadel.Image) (*placement, error) {
+	c.mux.Lock()
+	scheduler := c.schedulers[image.Type]
+	engines := c.listEngines()
+	c.mux.Unlock()
+
+	if scheduler == nil {
+		return nil, fmt.Errorf("no scheduler for type %s", image.Type)
+	}
+
+	// quotas are checked again when the placement is reserved
+	if err := c.ledger.admit([]*citadel.Reservation{c.reservation("", image)}); err != nil {
+		return nil, err
+	}
+
+	var (
+		accepted = []*citadel.Engine{}
+		rejected = make(map[string]*citadel.Decision)
+	)
+
+	for _, e := range engines {
+		if state := e.Health().State; state != citadel.Healthy {
+			rejected[e.ID] = citadel.Reject("health", "engine is %s", state)
+
+			continue
+		}
+
+		d, err := scheduler.Schedule(image, e)
+		if err != nil {
+			return nil, err
+		}
+
+		if !d.Accepted {
+			rejected[e.ID] = d
+
+			continue
+		}
+
+		accepted = append(accepted, e)
+	}
+
+	if len(accepted) == 0 {
+		return nil, &citadel.UnschedulableError{Image: image, Decisions: rejected}
+	}
+
+	preferences, err := c.preferences(scheduler, image, accepted)
+	if err != nil {
+		return nil, err
+	}
+
+	return &placement{
+		container: &citadel.Container{
+			Image: image,
+			Name:  image.ContainerName,
+		},
+		engines:     accepted,
+		preferences: preferences,
+		exclusive:   exclusive(scheduler, image),
+	}, nil
+}
+
+// preferences returns the score of each engine if the scheduler also scores engines
+func (c *Cluster) preferences(scheduler citadel.Scheduler, image *citadel.Image, engines []*citadel.Engine) (map[string]float64, error) {
+	out := make(map[string]float64)
+
+	scorer, ok := scheduler.(citadel.Scorer)
+	if !ok {
+		return out, nil
+	}
+
+	for _, e := range engines {
+		score, err := scorer.Score(image, e)
+		if err != nil {
+			return nil, err
+		}
+
+		out[e.ID] = score
+	}
+
+	return out, nil
+}
+
+// place decides the engine of every placement against one consistent view of the
+// ledger, each placement seeing the resources of the ones decided before it, and then
+// reserves all of them at once.  The whole decision is retried against fresh state if
+// any of the engines changed in the meantime.
+func (c *Cluster) place(placements []*placement) error {
+	for i := 0; i < maxCommitAttempts; i++ {
+		reservations, versions, err := c.plan(placements)
+		if err != nil {
+			if err == ErrConflict {
+				continue
+			}
+
+			return err
+		}
+
+		if err := c.ledger.reserve(versions, reservations); err != nil {
+			if err == ErrConflict {
+				continue
+			}
+
+			return err
+		}
+
+		for i, p := range placements {
+			p.reservation = reservations[i]
+
+			// the container binds the host ports that were reserved for it
+			if len(p.reservation.Ports) > 0 {
+				image := *p.container.Image
+				image.BindPorts = p.reservation.Ports
+				p.container.Image = &image
+			}
+		}
+
+		if err := c.preempt(placements); err != nil {
+			for _, r := range reservations {
+				c.ledger.release(r)
+			}
+
+			return err
+		}
+
+		return nil
+	}
+
+	return ErrConflict
+}
+
+// preempt stops the containers that the placements chose to preempt
+func (c *Cluster) preempt(placements []*placement) error {
+	for _, p := range placements {
+		for _, v := range p.victims {
+			engine, err := c.engine(v.EngineID)
+			if err != nil {
+				return err
+			}
+
+			victim := &citadel.Container{
+				ID:     v.ContainerID,
+				Image:  v.Image,
+				Engine: engine,
+			}
+
+			if v.Image != nil {
+				victim.Name = v.Image.ContainerName
+			}
+
+			if err := engine.Stop(victim); err != nil {
+				return err
+			}
+
+			c.ledger.remove(engine.ID, v.ContainerID)
+
+			c.publish(&citadel.Event{
+				Type:      citadel.PreemptedEvent,
+				Container: victim,
+				Engine:    engine,
+				Time:      time.Now(),
+			})
+		}
+	}
+
+	return nil
+}
+
+// plan decides the engine of each placement, the most constrained placements first so
+// that a placement that can only run on a few engines is not crowded out by one that could
+// have run elsewhere.  The reservations are returned in the order of the placements.
+// Host ports are checked against the ports held on the engine in the ledger, so two
+// placements binding the same port are rejected by the version check on commit.  The
+// ports of preempted containers are not reused by the placements of the same plan.
+func (c *Cluster) plan(placements []*placement) ([]*citadel.Reservation, map[string]uint64, error) {
+	var (
+		reservations = make([]*citadel.Reservation, len(placements))
+		versions     = make(map[string]uint64)
+		decided      = make(map[string]*planned)
+		evicted      = make(map[string]bool)
+		order        = make(byConstraint, len(placements))
+	)
+
+	for i, p := range placements {
+		order[i] = &constrained{index: i, placement: p}
+	}
+
+	sort.Stable(order)
+
+	for _, o := range order {
+		p := o.placement
+
+		var (
+			key       = p.container.Image.Key()
+			snapshots = []*citadel.EngineSnapshot{}
+			rejected  = make(map[string]*citadel.Decision)
+			allocated = make(map[string][]*citadel.Port)
+		)
+		p.victims = nil
+
+		for _, e := range p.engines {
+			s := c.ledger.snapshot(e, p.container.Image)
+
+			if v, ok := versions[e.ID]; ok && v != s.Version {
+				return nil, nil, ErrConflict
+			}
+			versions[e.ID] = s.Version
+
+			if d := decided[e.ID]; d != nil {
+				s.ReservedCpus += d.cpus
+				s.ReservedMemory += d.memory
+				s.ReservedDisk += d.disk
+				s.PendingCpus += d.cpus
+				s.PendingMemory += d.memory
+				s.Replicas += d.images[key]
+				s.Ports = append(s.Ports, d.ports...)
+			}
+
+			// the replicas in the snapshot include the pending reservations of other
+			// placements, so placements committed at the same time conflict on the version
+			if p.exclusive && s.Replicas > 0 {
+				rejected[e.ID] = citadel.Reject("exclusive", "engine already runs a replica of %s", p.container.Image.Name)
+
+				continue
+			}
+
+			if binds := p.container.Image.BindPorts; len(binds) > 0 {
+				ports, err := citadel.AllocatePorts(binds, s.Ports, e.PortRange)
+				if err != nil {
+					rejected[e.ID] = citadel.Reject("port", err.Error())
+
+					continue
+				}
+
+				allocated[e.ID] = ports
+			}
+
+			running := []*citadel.Reservation{}
+			for _, r := range s.Reservations {
+				if !evicted[r.ContainerID] {
+					running = append(running, r)
+				}
+			}
+			s.Reservations = running
+
+			s.Preference = p.preferences[e.ID]
+			snapshots = append(snapshots, s)
+		}
+
+		if len(snapshots) == 0 {
+			return nil, nil, &citadel.UnschedulableError{Image: p.container.Image, Decisions: rejected}
+		}
+
+		s, err := c.resourceManager.PlaceContainer(p.container, snapshots)
+		if preemptor, ok := c.resourceManager.(citadel.Preemptor); ok && err == citadel.ErrNoResources {
+			s, p.victims, err = preemptor.Preempt(p.container, snapshots)
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// the reservation holds the estimated resources while the container is started
+		// with the resources it requests
+		r := c.reservation(s.ID, p.container.Image)
+		r.Ports = allocated[s.ID]
+
+		d := decided[s.ID]
+		if d == nil {
+			d = &planned{images: make(map[string]int)}
+			decided[s.ID] = d
+		}
+
+		d.add(r)
+		d.images[key]++
+		d.ports = append(d.ports, r.Ports...)
+
+		for _, v := range p.victims {
+			evicted[v.ContainerID] = true
+			d.sub(v)
+
+			if v.Image != nil {
+				d.images[v.Image.Key()]--
+			}
+		}
+
+		reservations[o.index] = r
+	}
+
+	return reservations, versions, nil
+}
+
+// startAll runs the containers of the reserved placements.  If any of the containers
+// fails to start the containers already started are removed and the remaining
+// reservations are released.
+func (c *Cluster) startAll(placements []*placement, pull bool) error {
+	for i, p := range placements {
+		if err := c.run(p.container, p.reservation, pull); err != nil {
+			for _, rest := range placements[i+1:] {
+				c.ledger.release(rest.reservation)
+			}
+
+			for _, started := range placements[:i] {
+				c.Remove(started.container)
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exclusive returns true if containers of the image placed together must be on different
+// engines, either because of the image's affinities or because the scheduler for its type
+// never places the image on an engine already running it
+func exclusive(s citadel.Scheduler, image *citadel.Image) bool {
+	if antiAffinity(image) {
+		return true
+	}
+
+	if x, ok := s.(citadel.ExclusiveScheduler); ok && x.Exclusive(image) {
+		return true
+	}
+
+	if group, ok := s.(citadel.SchedulerGroup); ok {
+		for _, member := range group.Schedulers() {
+			if exclusive(member, image) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// reservation returns a new reservation for the image on the engine holding the resources
+// the resource manager estimates the image to use, if it estimates them
+func (c *Cluster) reservation(engineID string, image *citadel.Image) *citadel.Reservation {
+	r := newReservation(engineID, image)
+
+	estimator, ok := c.resourceManager.(citadel.Estimator)
+	if !ok {
+		return r
+	}
+
+	if cpus, memory, ok := estimator.Estimate(image); ok {
+		if cpus > 0 {
+			r.Cpus = cpus
+		}
+
+		if memory > 0 {
+			r.Memory = memory
+		}
+	}
+
+	return r
+}
+
+// antiAffinity returns true if the image has an affinity to never run next to its own image
+func antiAffinity(image *citadel.Image) bool {
+	info := citadel.ParseImageName(image.Name)
+
+	for _, a := range image.Affinity {
+		parts := strings.SplitN(a, "!=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) != "image" {
+			continue
+		}
+
+		switch strings.TrimSpace(parts[1]) {
+		case image.Name, info.Name, image.Key():
+			return true
+		}
+	}
+
+	return false
+}
+
+// constrained is a placement and its position in the placements being planned
+type constrained struct {
+	index     int
+	placement *placement
+}
+
+// byConstraint orders placements by the fewest accepted engines and then by the largest
+// request of cpus and memory
+type byConstraint []*constrained
+
+func (b byConstraint) Len() int      { return len(b) }
+func (b byConstraint) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byConstraint) Less(i, j int) bool {
+	if x, y := len(b[i].placement.engines), len(b[j].placement.engines); x != y {
+		return x < y
+	}
+
+	x, y := b[i].placement.container.Image, b[j].placement.container.Image
+	if x.Cpus != y.Cpus {
+		return x.Cpus > y.Cpus
+	}
+
+	return x.Memory > y.Memory
+}
@@ -0,0 +1,385 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/citadel/citadel"
+	"github.com/citadel/citadel/registry"
+	"github.com/citadel/citadel/scheduler"
+)
+
+func newPlacements(image *citadel.Image, engines []*citadel.Engine, count int) []*placement {
+	out := []*placement{}
+
+	for i := 0; i < count; i++ {
+		out = append(out, &placement{
+			container: &citadel.Container{Image: image},
+			engines:   engines,
+			exclusive: antiAffinity(image),
+		})
+	}
+
+	return out
+}
+
+func TestPlaceGroupAntiAffinity(t *testing.T) {
+	var (
+		reg     = registry.NewMemoryRegistry()
+		c       = &Cluster{resourceManager: scheduler.NewResourceManager(), ledger: newLedger(reg)}
+		engines = []*citadel.Engine{
+			{ID: "e1", Cpus: 4, Memory: 2048},
+			{ID: "e2", Cpus: 4, Memory: 2048},
+		}
+		image = &citadel.Image{Name: "redis", Cpus: 1, Memory: 512, Affinity: []string{"image!=redis"}}
+	)
+
+	for _, e := range engines {
+		c.ledger.addEngine(e.ID)
+	}
+
+	placements := newPlacements(image, engines, 2)
+	if err := c.place(placements); err != nil {
+		t.Fatal(err)
+	}
+
+	if placements[0].reservation.EngineID == placements[1].reservation.EngineID {
+		t.Fatalf("expected replicas on different engines; received %s for both", placements[0].reservation.EngineID)
+	}
+
+	if err := c.place(newPlacements(image, engines, 3)); err == nil {
+		t.Fatalf("expected a third replica to be rejected with two engines")
+	}
+}
+
+func TestPlaceGroupUniqueType(t *testing.T) {
+	var (
+		reg     = registry.NewMemoryRegistry()
+		c       = &Cluster{resourceManager: scheduler.NewResourceManager(), ledger: newLedger(reg)}
+		engines = []*citadel.Engine{
+			{ID: "e1", Cpus: 4, Memory: 2048},
+			{ID: "e2", Cpus: 4, Memory: 2048},
+		}
+		image  = &citadel.Image{Name: "redis", Type: "unique", Cpus: 1, Memory: 512}
+		unique = scheduler.NewMultiScheduler(&scheduler.LabelScheduler{}, &scheduler.UniqueScheduler{})
+	)
+
+	for _, e := range engines {
+		c.ledger.addEngine(e.ID)
+	}
+
+	if exclusive(scheduler.NewMultiScheduler(&scheduler.LabelScheduler{}), image) {
+		t.Fatalf("expected replicas of a label scheduled image to be able to share an engine")
+	}
+
+	placements := []*placement{}
+	for i := 0; i < 2; i++ {
+		placements = append(placements, &placement{
+			container: &citadel.Container{Image: image},
+			engines:   engines,
+			exclusive: exclusive(unique, image),
+		})
+	}
+
+	if err := c.place(placements); err != nil {
+		t.Fatal(err)
+	}
+
+	if placements[0].reservation.EngineID == placements[1].reservation.EngineID {
+		t.Fatalf("expected unique replicas on different engines; received %s for both", placements[0].reservation.EngineID)
+	}
+}
+
+func TestPlaceGroupSharesResources(t *testing.T) {
+	var (
+		reg     = registry.NewMemoryRegistry()
+		c       = &Cluster{resourceManager: scheduler.NewResourceManager(), ledger: newLedger(reg)}
+		engines = []*citadel.Engine{
+			{ID: "e1", Cpus: 2, Memory: 2048},
+		}
+		image = &citadel.Image{Name: "redis", Cpus: 1, Memory: 512}
+	)
+
+	c.ledger.addEngine("e1")
+
+	if err := c.place(newPlacements(image, engines, 3)); err == nil {
+		t.Fatalf("expected the group to exceed the cpus of the engine")
+	}
+
+	if reserved := c.ledger.reserved("e1"); reserved.cpus != 0 {
+		t.Fatalf("expected nothing reserved for a rejected group; received cpus %f", reserved.cpus)
+	}
+
+	if err := c.place(newPlacements(image, engines, 2)); err != nil {
+		t.Fatal(err)
+	}
+
+	if reserved := c.ledger.reserved("e1"); reserved.cpus != 2 {
+		t.Fatalf("expected 2 cpus reserved; received %f", reserved.cpus)
+	}
+}
+
+func TestPlaceGang(t *testing.T) {
+	var (
+		reg         = registry.NewMemoryRegistry()
+		c           = &Cluster{resourceManager: scheduler.NewResourceManager(), ledger: newLedger(reg)}
+		small       = &citadel.Engine{ID: "small", Cpus: 2, Memory: 1024}
+		large       = &citadel.Engine{ID: "large", Cpus: 8, Memory: 8192}
+		coordinator = &citadel.Image{Name: "coordinator", Cpus: 2, Memory: 1024}
+		worker      = &citadel.Image{Name: "worker", Cpus: 2, Memory: 1024}
+	)
+
+	c.ledger.addEngine(small.ID)
+	c.ledger.addEngine(large.ID)
+
+	// binpack prefers the small engine for every member but only the coordinator fits there
+	gang := []*placement{
+		{container: &citadel.Container{Image: coordinator}, engines: []*citadel.Engine{small}},
+		{container: &citadel.Container{Image: worker}, engines: []*citadel.Engine{small, large}},
+		{container: &citadel.Container{Image: worker}, engines: []*citadel.Engine{small, large}},
+	}
+
+	if err := c.place(gang); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expected := range []string{"small", "large", "large"} {
+		if id := gang[i].reservation.EngineID; id != expected {
+			t.Fatalf("expected member %d on %s; received %s", i, expected, id)
+		}
+	}
+
+	// a gang that does not fit as a whole reserves nothing
+	gang = []*placement{
+		{container: &citadel.Container{Image: worker}, engines: []*citadel.Engine{large}},
+		{container: &citadel.Container{Image: worker}, engines: []*citadel.Engine{small}},
+	}
+
+	if err := c.place(gang); err == nil {
+		t.Fatalf("expected a gang with a member that does not fit to be rejected")
+	}
+
+	if reserved := c.ledger.reserved("large"); reserved.cpus != 4 {
+		t.Fatalf("expected only the first gang to be reserved on large; received %f cpus", reserved.cpus)
+	}
+}
+
+func TestPlaceGangMostConstrainedFirst(t *testing.T) {
+	var (
+		reg         = registry.NewMemoryRegistry()
+		c           = &Cluster{resourceManager: scheduler.NewResourceManager(), ledger: newLedger(reg)}
+		engines     = []*citadel.Engine{{ID: "small", Cpus: 2, Memory: 1024}, {ID: "large", Cpus: 4, Memory: 2048}}
+		coordinator = &citadel.Image{Name: "coordinator", Cpus: 4, Memory: 2048}
+		worker      = &citadel.Image{Name: "worker", Cpus: 2, Memory: 1024, Strategy: "spread"}
+	)
+
+	for _, e := range engines {
+		c.ledger.addEngine(e.ID)
+	}
+
+	// spread prefers the large engine for the worker but only the coordinator needs it
+	gang := []*placement{
+		{container: &citadel.Container{Image: worker}, engines: engines},
+		{container: &citadel.Container{Image: coordinator}, engines: engines},
+	}
+
+	if err := c.place(gang); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expected := range []string{"small", "large"} {
+		if id := gang[i].reservation.EngineID; id != expected {
+			t.Fatalf("expected member %d on %s; received %s", i, expected, id)
+		}
+	}
+}
+
+func TestPlaceHostPorts(t *testing.T) {
+	var (
+		reg    = registry.NewMemoryRegistry()
+		c      = &Cluster{resourceManager: scheduler.NewResourceManager(), ledger: newLedger(reg)}
+		engine = &citadel.Engine{ID: "e1", Cpus: 4, Memory: 4096, PortRange: &citadel.PortRange{Start: 20000, End: 20001}}
+		fixed  = &citadel.Image{Name: "web", Cpus: 1, Memory: 512, BindPorts: []*citadel.Port{{Proto: "tcp", Port: 80, ContainerPort: 80}}}
+		first  = newPlacements(fixed, []*citadel.Engine{engine}, 1)
+		second = newPlacements(fixed, []*citadel.Engine{engine}, 1)
+	)
+
+	c.ledger.addEngine(engine.ID)
+
+	// both placements are decided against the same state and bind the same port
+	r1, v1, err := c.plan(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r2, v2, err := c.plan(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.ledger.reserve(v1, r1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.ledger.reserve(v2, r2); err != ErrConflict {
+		t.Fatalf("expected the second reservation of port 80 to conflict; received %v", err)
+	}
+
+	err = c.place(second)
+	if unschedulable, ok := err.(*citadel.UnschedulableError); !ok || unschedulable.Decisions["e1"].Scheduler != "port" {
+		t.Fatalf("expected port 80 to be rejected once reserved; received %v", err)
+	}
+
+	dynamic := &citadel.Image{Name: "worker", Cpus: 0.1, Memory: 64, BindPorts: []*citadel.Port{{Proto: "tcp", ContainerPort: 8080}}}
+
+	placements := newPlacements(dynamic, []*citadel.Engine{engine}, 2)
+	if err := c.place(placements); err != nil {
+		t.Fatal(err)
+	}
+
+	a, b := placements[0].container.Image.BindPorts[0].Port, placements[1].container.Image.BindPorts[0].Port
+	if a == 0 || a == b {
+		t.Fatalf("expected each replica to bind its own port from the range; received %d and %d", a, b)
+	}
+
+	if err := c.place(newPlacements(dynamic, []*citadel.Engine{engine}, 1)); err == nil {
+		t.Fatalf("expected the port range to be exhausted")
+	}
+}
+
+func TestPlaceExclusiveAcrossPlacements(t *testing.T) {
+	var (
+		reg    = registry.NewMemoryRegistry()
+		c      = &Cluster{resourceManager: scheduler.NewResourceManager(), ledger: newLedger(reg)}
+		engine = &citadel.Engine{ID: "e1", Cpus: 4, Memory: 2048}
+		image  = &citadel.Image{Name: "redis", Cpus: 1, Memory: 512, Affinity: []string{"image!=redis"}}
+		first  = newPlacements(image, []*citadel.Engine{engine}, 1)
+		second = newPlacements(image, []*citadel.Engine{engine}, 1)
+	)
+
+	c.ledger.addEngine(engine.ID)
+
+	// both placements are decided before either is committed
+	r1, v1, err := c.plan(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r2, v2, err := c.plan(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.ledger.reserve(v1, r1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.ledger.reserve(v2, r2); err != ErrConflict {
+		t.Fatalf("expected the second placement to conflict; received %v", err)
+	}
+
+	err = c.place(second)
+	if unschedulable, ok := err.(*citadel.UnschedulableError); !ok || unschedulable.Decisions["e1"].Scheduler != "exclusive" {
+		t.Fatalf("expected a second replica on the engine to be rejected; received %v", err)
+	}
+}
+
+func TestPlaceConcurrentUniqueGroups(t *testing.T) {
+	var (
+		reg     = registry.NewMemoryRegistry()
+		c       = &Cluster{resourceManager: scheduler.NewResourceManager(), ledger: newLedger(reg)}
+		engines = []*citadel.Engine{
+			{ID: "e1", Cpus: 4, Memory: 2048},
+			{ID: "e2", Cpus: 4, Memory: 2048},
+		}
+		image  = &citadel.Image{Name: "redis", Type: "unique", Cpus: 1, Memory: 512}
+		unique = scheduler.NewMultiScheduler(&scheduler.UniqueScheduler{})
+	)
+
+	for _, e := range engines {
+		c.ledger.addEngine(e.ID)
+	}
+
+	group := func(count int) []*placement {
+		out := []*placement{}
+		for i := 0; i < count; i++ {
+			out = append(out, &placement{
+				container: &citadel.Container{Image: image},
+				engines:   engines,
+				exclusive: exclusive(unique, image),
+			})
+		}
+
+		return out
+	}
+
+	first, second := group(2), group(2)
+
+	// both groups are decided before either is committed
+	r1, v1, err := c.plan(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r2, v2, err := c.plan(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.ledger.reserve(v1, r1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.ledger.reserve(v2, r2); err != ErrConflict {
+		t.Fatalf("expected the second group to conflict; received %v", err)
+	}
+
+	if err := c.place(second); err == nil {
+		t.Fatalf("expected the second group to be rejected with a replica on every engine")
+	}
+
+	if err := c.place(group(1)); err == nil {
+		t.Fatalf("expected a single replica to be rejected with a replica on every engine")
+	}
+
+	for _, e := range engines {
+		if reserved := c.ledger.reserved(e.ID); reserved.cpus != 1 {
+			t.Fatalf("expected one replica on %s; received %f cpus", e.ID, reserved.cpus)
+		}
+	}
+}
+
+// fixedEstimator estimates every image to use a quarter of a cpu and 128MB
+type fixedEstimator struct{}
+
+func (f *fixedEstimator) Estimate(i *citadel.Image) (float64, float64, bool) {
+	return 0.25, 128, true
+}
+
+func TestPlaceReservesEstimate(t *testing.T) {
+	var (
+		reg     = registry.NewMemoryRegistry()
+		rm      = scheduler.NewResourceManager()
+		c       = &Cluster{resourceManager: rm, ledger: newLedger(reg)}
+		engines = []*citadel.Engine{{ID: "e1", Cpus: 1, Memory: 1024}}
+		image   = &citadel.Image{Name: "redis", Cpus: 1, Memory: 512}
+	)
+
+	rm.Estimator = &fixedEstimator{}
+	c.ledger.addEngine("e1")
+
+	// four containers only fit when their estimates are reserved
+	placements := newPlacements(image, engines, 4)
+	if err := c.place(placements); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range placements {
+		if p.container.Image != image {
+			t.Fatalf("expected the container to be started with the requested resources")
+		}
+
+		if p.reservation.Cpus != 0.25 || p.reservation.Memory != 128 {
+			t.Fatalf("expected the estimate to be reserved; received cpus %f memory %f", p.reservation.Cpus, p.reservation.Memory)
+		}
+	}
+}
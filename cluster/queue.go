@@ -0,0 +1,260 @@
+package cluster
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/citadel/citadel"
+)
+
+const (
+	// queueInterval is how often the queue is evaluated when no capacity was freed
+	queueInterval = 30 * time.Second
+
+	// unschedulableAttempts is how many times an image that no engine is eligible to run
+	// is tried before it fails
+	unschedulableAttempts = 10
+)
+
+var (
+	ErrDequeued = errors.New("image was removed from the queue before it was started")
+)
+
+// Pending is an image waiting in the queue of the cluster until it can be placed
+type Pending struct {
+	Image    *citadel.Image
+	Priority int
+	Queued   time.Time
+
+	seq      uint64
+	pull     bool
+	callback func(*citadel.Container, error)
+
+	// unschedulable is the number of attempts that no engine was eligible to run the image
+	unschedulable int
+
+	done      chan struct{}
+	container *citadel.Container
+	err       error
+}
+
+// Wait blocks until the image is started or fails to start
+func (p *Pending) Wait() (*citadel.Container, error) {
+	<-p.done
+
+	return p.container, p.err
+}
+
+// Done is closed once the image is started or fails to start
+func (p *Pending) Done() <-chan struct{} {
+	return p.done
+}
+
+func (p *Pending) complete(container *citadel.Container, err error) {
+	p.container, p.err = container, err
+	close(p.done)
+
+	if p.callback != nil {
+		go p.callback(container, err)
+	}
+}
+
+// Enqueue adds the image to the queue of images to start once there is capacity in the
+// cluster.  Images with a higher priority are started first and images of the same
+// priority in the order they were queued, or in the order of the cluster's allocator.
+// The queue is evaluated again whenever a container dies or is destroyed and when an
+// engine is added or recovers.  The callback, if not nil, is called with the result once
+// the image is started or fails to start.
+func (c *Cluster) Enqueue(image *citadel.Image, priority int, pull bool, callback func(*citadel.Container, error)) *Pending {
+	c.queueMux.Lock()
+	c.queueSeq++
+
+	p := &Pending{
+		Image:    image,
+		Priority: priority,
+		Queued:   time.Now(),
+		seq:      c.queueSeq,
+		pull:     pull,
+		callback: callback,
+		done:     make(chan struct{}),
+	}
+
+	c.queue = append(c.queue, p)
+	c.queueMux.Unlock()
+
+	c.processQueue()
+
+	return p
+}
+
+// Dequeue removes the image from the queue returning false if it was already started
+func (c *Cluster) Dequeue(p *Pending) bool {
+	if !c.removeQueued(p) {
+		return false
+	}
+
+	p.complete(nil, ErrDequeued)
+
+	return true
+}
+
+// SetAllocator sets the allocator that orders the queued images of the same priority
+// between tenants.  Without an allocator they are started in the order they were queued.
+func (c *Cluster) SetAllocator(a citadel.Allocator) {
+	c.queueMux.Lock()
+	defer c.queueMux.Unlock()
+
+	c.allocator = a
+}
+
+// Queue returns the images waiting in the queue in the order they are started
+func (c *Cluster) Queue() []*Pending {
+	c.queueMux.Lock()
+	out := append([]*Pending{}, c.queue...)
+	allocator := c.allocator
+	c.queueMux.Unlock()
+
+	sort.Sort(byPriority(out))
+
+	if allocator == nil || len(out) == 0 {
+		return out
+	}
+
+	var (
+		info    = c.allocation()
+		ordered = []*Pending{}
+	)
+
+	for start := 0; start < len(out); {
+		end := start
+		for end < len(out) && out[end].Priority == out[start].Priority {
+			end++
+		}
+
+		images := []*citadel.Image{}
+		for _, p := range out[start:end] {
+			images = append(images, p.Image)
+		}
+
+		for _, i := range allocator.Order(info, images) {
+			ordered = append(ordered, out[start+i])
+		}
+
+		start = end
+	}
+
+	return ordered
+}
+
+// allocation returns the resources of the cluster and of each tenant without asking the
+// engines for their images like ClusterInfo
+func (c *Cluster) allocation() *citadel.ClusterInfo {
+	info := &citadel.ClusterInfo{
+		Tenants: c.ledger.tenants(),
+	}
+
+	for _, e := range c.Engines() {
+		reserved := c.ledger.reserved(e.ID)
+
+		info.EngineCount++
+		info.Cpus += e.Cpus
+		info.Memory += e.Memory
+		info.Disk += e.Disk
+		info.ReservedCpus += reserved.cpus
+		info.ReservedMemory += reserved.memory
+		info.ReservedDisk += reserved.disk
+		info.ContainerCount += reserved.containers
+	}
+
+	return info
+}
+
+// processQueue asks the queue loop to evaluate the queue without waiting for the next interval
+func (c *Cluster) processQueue() {
+	select {
+	case c.queueChanged <- struct{}{}:
+	default:
+	}
+}
+
+func (c *Cluster) queueLoop() {
+	ticker := time.NewTicker(queueInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.startQueued()
+		case <-c.queueChanged:
+			c.startQueued()
+		}
+	}
+}
+
+// startQueued starts the queued images in order.  When an image does not fit on any engine
+// the images after it keep waiting so that large images are not starved by smaller ones.
+// An image that lost every commit to concurrent placements also stays queued and ends the
+// pass.  Images that no engine is eligible to run or that exceed the quota of their tenant
+// do not hold up the rest of the queue.  Images that no engine is eligible to run fail with
+// the decisions of the engines after unschedulableAttempts.
+func (c *Cluster) startQueued() {
+	for _, p := range c.Queue() {
+		container, err := c.Start(p.Image, p.pull)
+
+		switch err.(type) {
+		case *citadel.QuotaError:
+			// the image waits for containers of its tenant to exit
+			continue
+		case *citadel.UnschedulableError:
+			// engines may recover or free their ports before the image fails
+			if p.unschedulable++; p.unschedulable < unschedulableAttempts {
+				continue
+			}
+		}
+
+		if err == citadel.ErrNoResources || err == ErrConflict {
+			return
+		}
+
+		if !c.removeQueued(p) {
+			// the image was dequeued while it was being started
+			if err == nil {
+				c.Remove(container)
+			}
+
+			continue
+		}
+
+		p.complete(container, err)
+	}
+}
+
+func (c *Cluster) removeQueued(p *Pending) bool {
+	c.queueMux.Lock()
+	defer c.queueMux.Unlock()
+
+	for i, q := range c.queue {
+		if q == p {
+			c.queue = append(c.queue[:i], c.queue[i+1:]...)
+
+			return true
+		}
+	}
+
+	return false
+}
+
+type byPriority []*Pending
+
+func (b byPriority) Len() int      { return len(b) }
+func (b byPriority) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byPriority) Less(i, j int) bool {
+	if b[i].Priority != b[j].Priority {
+		return b[i].Priority > b[j].Priority
+	}
+
+	return b[i].seq < b[j].seq
+}
@@ -0,0 +1,153 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/citadel/citadel"
+	"github.com/citadel/citadel/registry"
+	"github.com/citadel/citadel/scheduler"
+	"github.com/samalba/dockerclient"
+)
+
+func TestQueueOrder(t *testing.T) {
+	c := &Cluster{queueChanged: make(chan struct{}, 1)}
+
+	var (
+		low    = c.Enqueue(&citadel.Image{Name: "low"}, 0, false, nil)
+		first  = c.Enqueue(&citadel.Image{Name: "first"}, 10, false, nil)
+		second = c.Enqueue(&citadel.Image{Name: "second"}, 10, false, nil)
+	)
+
+	queue := c.Queue()
+	if len(queue) != 3 || queue[0] != first || queue[1] != second || queue[2] != low {
+		t.Fatalf("expected images by priority then in the order they were queued; received %v", queue)
+	}
+
+	if !c.Dequeue(second) {
+		t.Fatalf("expected second to be removed from the queue")
+	}
+
+	if _, err := second.Wait(); err != ErrDequeued {
+		t.Fatalf("expected waiting on a dequeued image to return ErrDequeued; received %v", err)
+	}
+
+	if c.Dequeue(second) {
+		t.Fatalf("expected second to already be removed from the queue")
+	}
+
+	if queue := c.Queue(); len(queue) != 2 {
+		t.Fatalf("expected 2 images in the queue; received %d", len(queue))
+	}
+}
+
+func TestQueueFairShare(t *testing.T) {
+	c := &Cluster{
+		engines:      map[string]*citadel.Engine{"local": {ID: "local", Cpus: 4, Memory: 4096}},
+		ledger:       newLedger(registry.NewMemoryRegistry()),
+		queueChanged: make(chan struct{}, 1),
+	}
+
+	c.SetAllocator(&scheduler.DRFAllocator{})
+
+	var (
+		a1     = c.Enqueue(&citadel.Image{Name: "a", Tenant: "a", Cpus: 1}, 0, false, nil)
+		a2     = c.Enqueue(&citadel.Image{Name: "a", Tenant: "a", Cpus: 1}, 0, false, nil)
+		b      = c.Enqueue(&citadel.Image{Name: "b", Tenant: "b", Cpus: 1}, 0, false, nil)
+		urgent = c.Enqueue(&citadel.Image{Name: "a", Tenant: "a", Cpus: 1}, 1, false, nil)
+	)
+
+	queue := c.Queue()
+	if len(queue) != 4 || queue[0] != urgent || queue[1] != a1 || queue[2] != b || queue[3] != a2 {
+		t.Fatalf("expected tenant b to be served before the second image of tenant a; received %v", queue)
+	}
+}
+
+// conflictingManager loses every commit to a concurrent placement
+type conflictingManager struct{}
+
+func (m *conflictingManager) PlaceContainer(c *citadel.Container, engines []*citadel.EngineSnapshot) (*citadel.EngineSnapshot, error) {
+	return nil, ErrConflict
+}
+
+func TestQueueKeepsConflictedImages(t *testing.T) {
+	client, err := dockerclient.NewDockerClient("http://127.0.0.1:2375", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine := &citadel.Engine{ID: "local", Cpus: 4, Memory: 4096}
+	engine.SetClient(client)
+
+	c := &Cluster{
+		engines:         map[string]*citadel.Engine{"local": engine},
+		schedulers:      map[string]citadel.Scheduler{"service": scheduler.NewMultiScheduler()},
+		resourceManager: &conflictingManager{},
+		ledger:          newLedger(registry.NewMemoryRegistry()),
+		queueChanged:    make(chan struct{}, 1),
+	}
+	c.ledger.addEngine(engine.ID)
+
+	p := c.Enqueue(&citadel.Image{Name: "redis", Type: "service", Cpus: 1}, 0, false, nil)
+
+	c.startQueued()
+
+	select {
+	case <-p.Done():
+		_, err := p.Wait()
+		t.Fatalf("expected the image to stay queued after a conflict; received %v", err)
+	default:
+	}
+
+	if queue := c.Queue(); len(queue) != 1 || queue[0] != p {
+		t.Fatalf("expected the image to still be queued; received %v", queue)
+	}
+}
+
+func TestQueueFailsUnschedulableImages(t *testing.T) {
+	client, err := dockerclient.NewDockerClient("http://127.0.0.1:2375", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine := &citadel.Engine{ID: "local", Cpus: 4, Memory: 4096}
+	engine.SetClient(client)
+
+	c := &Cluster{
+		engines:         map[string]*citadel.Engine{"local": engine},
+		schedulers:      map[string]citadel.Scheduler{"service": &scheduler.LabelScheduler{}},
+		resourceManager: &conflictingManager{},
+		ledger:          newLedger(registry.NewMemoryRegistry()),
+		queueChanged:    make(chan struct{}, 1),
+	}
+	c.ledger.addEngine(engine.ID)
+
+	p := c.Enqueue(&citadel.Image{Name: "redis", Type: "service", Cpus: 1, Labels: []string{"gpu"}}, 0, false, nil)
+
+	for i := 1; i < unschedulableAttempts; i++ {
+		c.startQueued()
+	}
+
+	select {
+	case <-p.Done():
+		_, err := p.Wait()
+		t.Fatalf("expected the image to stay queued before the last attempt; received %v", err)
+	default:
+	}
+
+	c.startQueued()
+
+	_, err = p.Wait()
+
+	unschedulable, ok := err.(*citadel.UnschedulableError)
+	if !ok {
+		t.Fatalf("expected an UnschedulableError; received %v", err)
+	}
+
+	if d := unschedulable.Decisions["local"]; d == nil || d.Accepted {
+		t.Fatalf("expected the decision of the rejected engine; received %v", unschedulable.Decisions)
+	}
+
+	if queue := c.Queue(); len(queue) != 0 {
+		t.Fatalf("expected the image to be removed from the queue; received %v", queue)
+	}
+}
@@ -0,0 +1,108 @@
+package cluster
+
+import (
+	"time"
+
+	"github.com/citadel/citadel"
+)
+
+// reschedule starts the containers that the ledger holds on the unreachable engine on the
+// healthy engines of the cluster.  The containers that are moved are removed from the
+// engine if it recovers so that they do not run twice.  Containers that fail to start
+// stay on the engine and are tried again on the next health check while the engine is
+// unreachable.  The containers of services are replaced by the service reconciler instead.
+func (c *Cluster) reschedule(e *citadel.Engine) {
+	// one reschedule runs at a time, serialized with converging the services
+	c.serviceMux.Lock()
+	defer c.serviceMux.Unlock()
+
+	// the engine may have recovered while waiting
+	if e.Health().State != citadel.Unreachable {
+		return
+	}
+
+	// the reconciler no longer counts the replicas of the engine
+	c.convergeServices()
+
+	for _, r := range c.ledger.running(e.ID) {
+		if r.Image == nil || r.Image.Service != "" {
+			continue
+		}
+
+		lost := &citadel.Container{
+			ID:     r.ContainerID,
+			Name:   r.Image.ContainerName,
+			Image:  r.Image,
+			Engine: e,
+		}
+
+		image := *r.Image
+
+		container, err := c.Start(&image, false)
+		if err != nil {
+			c.mux.Lock()
+			retried := c.unmoved[e.ID][r.ContainerID]
+			if c.unmoved[e.ID] == nil {
+				c.unmoved[e.ID] = make(map[string]bool)
+			}
+			c.unmoved[e.ID][r.ContainerID] = true
+			c.mux.Unlock()
+
+			// the failure is only published for the first attempt
+			if !retried {
+				c.publish(&citadel.Event{
+					Type:      citadel.RescheduleFailedEvent,
+					Container: lost,
+					Engine:    e,
+					Time:      time.Now(),
+				})
+			}
+
+			continue
+		}
+
+		c.ledger.remove(e.ID, r.ContainerID)
+
+		c.mux.Lock()
+		if c.moved[e.ID] == nil {
+			c.moved[e.ID] = make(map[string]bool)
+		}
+		c.moved[e.ID][r.ContainerID] = true
+		delete(c.unmoved[e.ID], r.ContainerID)
+		c.mux.Unlock()
+
+		c.publish(&citadel.Event{
+			Type:      citadel.RescheduledEvent,
+			Container: container,
+			Engine:    container.Engine,
+			Time:      time.Now(),
+			Previous:  lost,
+		})
+	}
+}
+
+// unmovedContainers returns true if containers of the engine failed to be rescheduled
+func (c *Cluster) unmovedContainers(e *citadel.Engine) bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return len(c.unmoved[e.ID]) > 0
+}
+
+// removeMoved removes the containers that were rescheduled while the engine was unreachable
+// and stops retrying the ones that were not
+func (c *Cluster) removeMoved(e *citadel.Engine) {
+	c.mux.Lock()
+	moved := c.moved[e.ID]
+	delete(c.moved, e.ID)
+	delete(c.unmoved, e.ID)
+	c.mux.Unlock()
+
+	for id := range moved {
+		container := &citadel.Container{ID: id, Engine: e}
+
+		if err := e.Remove(container); err == nil {
+			c.ledger.remove(e.ID, id)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/citadel/citadel"
+)
+
+// serviceInterval is how often the containers of the services are compared with their
+// desired state when no container has died in the meantime
+const serviceInterval = 30 * time.Second
+
+var (
+	ErrInvalidService = errors.New("service requires a name, an image and a replica count that is not negative")
+)
+
+// SaveService adds or updates the desired state of the service.  The cluster starts
+// or removes containers of the service until the number of running containers matches
+// the service's replicas.
+func (c *Cluster) SaveService(s *citadel.Service) error {
+	if s.Name == "" || s.Image == nil || s.Replicas < 0 {
+		return ErrInvalidService
+	}
+
+	if err := c.registry.SaveService(s); err != nil {
+		return err
+	}
+
+	c.convergeServices()
+
+	return nil
+}
+
+// RemoveService removes the service and all of its containers from the cluster
+func (c *Cluster) RemoveService(name string) error {
+	s, err := c.service(name)
+	if err != nil {
+		return err
+	}
+
+	c.serviceMux.Lock()
+	defer c.serviceMux.Unlock()
+
+	if err := c.registry.DeleteService(s); err != nil {
+		return err
+	}
+
+	containers, err := c.serviceContainers()
+	if err != nil {
+		return err
+	}
+
+	for _, container := range containers[name] {
+		if err := c.Remove(container); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Services returns the services whose desired state is kept by the cluster
+func (c *Cluster) Services() ([]*citadel.Service, error) {
+	return c.registry.FetchServices()
+}
+
+func (c *Cluster) service(name string) (*citadel.Service, error) {
+	services, err := c.registry.FetchServices()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range services {
+		if s.Name == name {
+			return s, nil
+		}
+	}
+
+	return nil, fmt.Errorf("service %s does not exist", name)
+}
+
+// convergeServices asks the service loop to compare the services with their containers
+// without waiting for the next interval
+func (c *Cluster) convergeServices() {
+	select {
+	case c.servicesChanged <- struct{}{}:
+	default:
+	}
+}
+
+// serviceLoop keeps the containers of the services in their desired state
+func (c *Cluster) serviceLoop() {
+	ticker := time.NewTicker(serviceInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.reconcileServices()
+		case <-c.servicesChanged:
+			c.reconcileServices()
+		}
+	}
+}
+
+// reconcileServices starts the missing containers of every service and removes the
+// containers that are stopped or in excess of the service's replicas
+func (c *Cluster) reconcileServices() error {
+	c.serviceMux.Lock()
+	defer c.serviceMux.Unlock()
+
+	services, err := c.registry.FetchServices()
+	if err != nil {
+		return err
+	}
+
+	containers, err := c.serviceContainers()
+	if err != nil {
+		return err
+	}
+
+	var first error
+	for _, s := range services {
+		if err := c.reconcileService(s, containers[s.Name]); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+func (c *Cluster) reconcileService(s *citadel.Service, containers []*citadel.Container) error {
+	names, remove := converge(s, containers)
+
+	for _, container := range remove {
+		if err := c.Remove(container); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range names {
+		image := *s.Image
+		image.Service = s.Name
+		image.ContainerName = name
+
+		if _, err := c.Start(&image, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// serviceContainers returns the containers of the reachable engines by service name.  The
+// containers of unreachable engines are not counted so that they are replaced.  An error
+// is returned if a reachable engine fails to list its containers so that replicas are not
+// started for containers that are only missing from the listing.
+func (c *Cluster) serviceContainers() (map[string][]*citadel.Container, error) {
+	out := make(map[string][]*citadel.Container)
+
+	for _, e := range c.Engines() {
+		if !e.IsConnected() || e.Health().State == citadel.Unreachable {
+			continue
+		}
+
+		containers, err := e.ListContainers(true)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, container := range containers {
+			if name := container.Image.Service; name != "" {
+				out[name] = append(out[name], container)
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// converge returns the names of the containers to start and the containers to remove for
+// the containers of the service to match its desired state.  The names are empty if the
+// service's image does not set a container name, otherwise the first free <name>-<n> is used.
+func converge(s *citadel.Service, containers []*citadel.Container) ([]string, []*citadel.Container) {
+	var (
+		names   = []string{}
+		remove  = []*citadel.Container{}
+		running = []*citadel.Container{}
+	)
+
+	for _, container := range containers {
+		if container.State != "running" {
+			remove = append(remove, container)
+
+			continue
+		}
+
+		running = append(running, container)
+	}
+
+	sort.Sort(byID(running))
+
+	if len(running) > s.Replicas {
+		remove = append(remove, running[s.Replicas:]...)
+		running = running[:s.Replicas]
+	}
+
+	if missing := s.Replicas - len(running); missing > 0 {
+		names = containerNames(s.Image, running, missing)
+	}
+
+	return names, remove
+}
+
+// containerNames returns count names for new containers of the image that are not used
+// by the containers.  The names are empty if the image does not set a container name.
+func containerNames(image *citadel.Image, containers []*citadel.Container, count int) []string {
+	var (
+		names = []string{}
+		used  = make(map[string]bool)
+	)
+
+	for _, container := range containers {
+		used[strings.TrimPrefix(container.Name, "/")] = true
+	}
+
+	for i := 0; len(names) < count; i++ {
+		name := ""
+
+		if image.ContainerName != "" {
+			name = fmt.Sprintf("%s-%d", image.ContainerName, i)
+			if used[name] {
+				continue
+			}
+		}
+
+		names = append(names, name)
+	}
+
+	return names
+}
+
+type byID []*citadel.Container
+
+func (b byID) Len() int           { return len(b) }
+func (b byID) Less(i, j int) bool { return b[i].ID < b[j].ID }
+func (b byID) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
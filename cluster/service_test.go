@@ -0,0 +1,42 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/citadel/citadel"
+)
+
+func TestConvergeService(t *testing.T) {
+	var (
+		image   = &citadel.Image{Name: "nginx", ContainerName: "web"}
+		service = &citadel.Service{Name: "web", Image: image, Replicas: 3}
+	)
+
+	names, remove := converge(service, []*citadel.Container{
+		{ID: "a", Name: "/web-0", State: "running"},
+		{ID: "b", Name: "/web-2", State: "stopped"},
+	})
+
+	if len(remove) != 1 || remove[0].ID != "b" {
+		t.Fatalf("expected stopped container b to be removed; received %v", remove)
+	}
+
+	if len(names) != 2 || names[0] != "web-1" || names[1] != "web-2" {
+		t.Fatalf("expected web-1 and web-2 to be started; received %v", names)
+	}
+
+	service.Replicas = 1
+
+	names, remove = converge(service, []*citadel.Container{
+		{ID: "b", Name: "/web-1", State: "running"},
+		{ID: "a", Name: "/web-0", State: "running"},
+	})
+
+	if len(names) != 0 {
+		t.Fatalf("expected no containers to be started; received %v", names)
+	}
+
+	if len(remove) != 1 || remove[0].ID != "b" {
+		t.Fatalf("expected container b to be removed; received %v", remove)
+	}
+}
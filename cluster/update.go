@@ -0,0 +1,191 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/citadel/citadel"
+)
+
+// UpdateConfig controls how the containers of a service are replaced during a rolling update
+type UpdateConfig struct {
+	// BatchSize is the number of containers replaced at a time, 1 if not set
+	BatchSize int
+
+	// Timeout is how long to wait for the new containers of a batch to be running and
+	// healthy, 30 seconds if not set
+	Timeout time.Duration
+
+	// HealthCheck is called with every new container once it is running.  The batch fails
+	// if it does not return nil before the timeout.
+	HealthCheck func(*citadel.Container) error
+}
+
+// waitInterval is how often a new container is checked while waiting for it to be running
+const waitInterval = 1 * time.Second
+
+// RollingUpdate replaces the running containers of the service with containers of the
+// image in batches.  The new containers of a batch are placed by the scheduler and the old
+// containers are only stopped once the new ones are running and healthy.  If a batch fails
+// every new container is removed, the stopped containers are started again and the service
+// is left unchanged.  The old containers are removed once all the batches succeed.
+func (c *Cluster) RollingUpdate(name string, image *citadel.Image, config *UpdateConfig) error {
+	if config == nil {
+		config = &UpdateConfig{}
+	}
+
+	batchSize := config.BatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	c.serviceMux.Lock()
+	defer c.serviceMux.Unlock()
+
+	s, err := c.service(name)
+	if err != nil {
+		return err
+	}
+
+	containers, err := c.serviceContainers()
+	if err != nil {
+		return err
+	}
+
+	old := []*citadel.Container{}
+	for _, container := range containers[name] {
+		if container.State == "running" {
+			old = append(old, container)
+		}
+	}
+	sort.Sort(byID(old))
+
+	updated := *image
+	updated.Service = name
+
+	var (
+		started = []*citadel.Container{}
+		stopped = []*citadel.Container{}
+	)
+
+	for i := 0; i < len(old); i += batchSize {
+		end := i + batchSize
+		if end > len(old) {
+			end = len(old)
+		}
+
+		batch, err := c.startBatch(&updated, append(old, started...), end-i, config)
+		started = append(started, batch...)
+
+		if err != nil {
+			c.rollback(started, stopped)
+
+			return err
+		}
+
+		for _, container := range old[i:end] {
+			if err := container.Engine.Stop(container); err != nil {
+				c.rollback(started, stopped)
+
+				return err
+			}
+
+			stopped = append(stopped, container)
+		}
+	}
+
+	// the service is owned by the registry so the new image is saved on a copy, leaving
+	// the service unchanged if the save fails
+	updatedService := *s
+	updatedService.Image = image
+
+	if err := c.registry.SaveService(&updatedService); err != nil {
+		c.rollback(started, stopped)
+
+		return err
+	}
+
+	for _, container := range stopped {
+		c.Remove(container)
+	}
+
+	return nil
+}
+
+// startBatch starts count containers of the image and waits for them to be running and
+// healthy, returning the containers that were started even if the batch failed
+func (c *Cluster) startBatch(image *citadel.Image, existing []*citadel.Container, count int, config *UpdateConfig) ([]*citadel.Container, error) {
+	started := []*citadel.Container{}
+
+	for _, name := range containerNames(image, existing, count) {
+		i := *image
+		i.ContainerName = name
+
+		container, err := c.Start(&i, false)
+		if err != nil {
+			return started, err
+		}
+
+		started = append(started, container)
+	}
+
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	deadline := time.Now().Add(timeout)
+
+	for _, container := range started {
+		if err := waitHealthy(container, config.HealthCheck, deadline); err != nil {
+			return started, err
+		}
+	}
+
+	return started, nil
+}
+
+// waitHealthy waits until the container is running and passes the health check
+func waitHealthy(container *citadel.Container, check func(*citadel.Container) error, deadline time.Time) error {
+	for {
+		err := containerHealth(container, check)
+		if err == nil {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s did not become healthy: %s", container, err)
+		}
+
+		time.Sleep(waitInterval)
+	}
+}
+
+func containerHealth(container *citadel.Container, check func(*citadel.Container) error) error {
+	current, err := citadel.FromDockerContainer(container.ID, container.Image.Name, container.Engine)
+	if err != nil {
+		return err
+	}
+
+	if current.State != "running" {
+		return fmt.Errorf("container is %s", current.State)
+	}
+
+	if check == nil {
+		return nil
+	}
+
+	return check(current)
+}
+
+// rollback removes the new containers of a failed update and starts the old containers
+// that were already stopped
+func (c *Cluster) rollback(started, stopped []*citadel.Container) {
+	for _, container := range started {
+		c.Remove(container)
+	}
+
+	for _, container := range stopped {
+		container.Engine.Restart(container, 10)
+	}
+}
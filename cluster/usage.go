@@ -0,0 +1,47 @@
+package cluster
+
+import (
+	"sync"
+	"time"
+
+	"github.com/citadel/citadel"
+)
+
+// usageInterval is how often the live resource usage of the engines is sampled
+const usageInterval = 15 * time.Second
+
+// usageLoop periodically samples the resource usage of every reachable engine
+func (c *Cluster) usageLoop() {
+	ticker := time.NewTicker(usageInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.sampleUsage()
+		}
+	}
+}
+
+func (c *Cluster) sampleUsage() {
+	var wg sync.WaitGroup
+
+	for _, e := range c.Engines() {
+		if !e.IsConnected() {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(e *citadel.Engine) {
+			defer wg.Done()
+
+			// a failed sample keeps the previous usage until the next interval
+			e.SampleUsage()
+		}(e)
+	}
+
+	wg.Wait()
+}
@@ -21,6 +21,9 @@ type Container struct {
 
 	// Ports are the public port mappings for the container
 	Ports []*Port `json:"ports,omitempty"`
+
+	// ExitCode is the exit code of a container that is no longer running
+	ExitCode int `json:"exit_code,omitempty"`
 }
 
 func (c *Container) String() string {
@@ -2,15 +2,21 @@ package citadel
 
 import (
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/samalba/dockerclient"
 )
 
+var (
+	ErrEngineNotConnected = errors.New("engine is not connected to docker's REST API")
+)
+
 type Engine struct {
 	ID     string   `json:"id,omitempty"`
 	Addr   string   `json:"addr,omitempty"`
@@ -18,8 +24,29 @@ type Engine struct {
 	Memory float64  `json:"memory,omitempty"`
 	Labels []string `json:"labels,omitempty"`
 
+	// Disk is the disk space in MB available to containers
+	Disk float64 `json:"disk,omitempty"`
+
+	// Volumes are the named volumes hosted on the engine
+	Volumes []string `json:"volumes,omitempty"`
+
+	// PortRange is the range of host ports allocated to containers that bind
+	// a port without specifying the host port
+	PortRange *PortRange `json:"port_range,omitempty"`
+
 	client       *dockerclient.DockerClient
 	eventHandler EventHandler
+
+	healthMux sync.Mutex
+	health    *Health
+
+	usageMux   sync.Mutex
+	usage      *Usage
+	cpuSamples map[string]*cpuSample
+
+	// portMux serializes allocating ports from the port range with starting
+	// the container that binds them
+	portMux sync.Mutex
 }
 
 func (e *Engine) Connect(config *tls.Config) error {
@@ -38,8 +65,69 @@ func (e *Engine) SetClient(c *dockerclient.DockerClient) {
 }
 
 // IsConnected returns true if the engine is connected to a remote docker API
+// that is reachable
 func (e *Engine) IsConnected() bool {
-	return e.client != nil
+	return e.client != nil && e.Health().State != Unreachable
+}
+
+// Health returns the result of the last health check.  Engines that have not been
+// checked yet are considered healthy if they have a client.
+func (e *Engine) Health() *Health {
+	e.healthMux.Lock()
+	defer e.healthMux.Unlock()
+
+	if e.health == nil {
+		if e.client == nil {
+			return &Health{State: Unreachable, Error: ErrEngineNotConnected.Error()}
+		}
+
+		return &Health{State: Healthy}
+	}
+
+	h := *e.health
+
+	return &h
+}
+
+// CheckHealth pings the remote API and updates the health of the engine returning
+// the new health and true if the state of the engine changed
+func (e *Engine) CheckHealth() (*Health, bool) {
+	var (
+		previous = e.Health()
+		err      = ErrEngineNotConnected
+		start    = time.Now()
+	)
+
+	if e.client != nil {
+		_, err = e.client.Version()
+	}
+
+	h := &Health{
+		State:   Healthy,
+		Latency: time.Since(start),
+		Checked: time.Now(),
+	}
+
+	switch {
+	case err != nil:
+		h.Failures = previous.Failures + 1
+		h.Error = err.Error()
+		h.State = Degraded
+
+		if e.client == nil || h.Failures >= UnreachableFailures {
+			h.State = Unreachable
+		}
+	case h.Latency > DegradedLatency:
+		h.State = Degraded
+	}
+
+	e.healthMux.Lock()
+	e.health = h
+	e.healthMux.Unlock()
+
+	out := *h
+
+	return &out, h.State != previous.State
 }
 
 func (e *Engine) Pull(image string) error {
@@ -64,9 +152,25 @@ func (e *Engine) Start(c *Container, pullImage bool) error {
 
 	env = append(env,
 		fmt.Sprintf("_citadel_type=%s", i.Type),
-		fmt.Sprintf("_citadel_labels=%s", strings.Join(i.Labels, ",")),
+		fmt.Sprintf("_citadel_labels=%s", encodeLabels(i.Labels)),
 	)
 
+	if i.Disk > 0 {
+		env = append(env, fmt.Sprintf("_citadel_disk=%f", i.Disk))
+	}
+
+	if i.Service != "" {
+		env = append(env, fmt.Sprintf("_citadel_service=%s", i.Service))
+	}
+
+	if i.Tenant != "" {
+		env = append(env, fmt.Sprintf("_citadel_tenant=%s", i.Tenant))
+	}
+
+	if i.Priority != 0 {
+		env = append(env, fmt.Sprintf("_citadel_priority=%d", i.Priority))
+	}
+
 	vols := make(map[string]struct{})
 	binds := []string{}
 	for _, v := range i.Volumes {
@@ -98,6 +202,7 @@ func (e *Engine) Start(c *Container, pullImage bool) error {
 		PortBindings:    make(map[string][]dockerclient.PortBinding),
 		Links:           links,
 		Binds:           binds,
+		VolumesFrom:     i.VolumesFrom,
 		RestartPolicy: dockerclient.RestartPolicy{
 			Name:              i.RestartPolicy.Name,
 			MaximumRetryCount: i.RestartPolicy.MaximumRetryCount,
@@ -105,7 +210,23 @@ func (e *Engine) Start(c *Container, pullImage bool) error {
 		NetworkMode: i.NetworkMode,
 	}
 
-	for _, b := range i.BindPorts {
+	if pullImage {
+		if err := e.Pull(i.Name); err != nil {
+			return err
+		}
+	}
+
+	if e.PortRange != nil {
+		e.portMux.Lock()
+		defer e.portMux.Unlock()
+	}
+
+	ports, err := e.allocatePorts(i.BindPorts)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range ports {
 		key := fmt.Sprintf("%d/%s", b.ContainerPort, b.Proto)
 		config.ExposedPorts[key] = struct{}{}
 
@@ -116,14 +237,8 @@ func (e *Engine) Start(c *Container, pullImage bool) error {
 			},
 		}
 	}
-	
-	config.HostConfig = *hostConfig
 
-	if pullImage {
-		if err := e.Pull(i.Name); err != nil {
-			return err
-		}
-	}
+	config.HostConfig = *hostConfig
 
 	if c.ID, err = client.CreateContainer(config, c.Name); err != nil {
 		return err
@@ -136,6 +251,42 @@ func (e *Engine) Start(c *Container, pullImage bool) error {
 	return e.updatePortInformation(c)
 }
 
+// allocatePorts returns the ports to bind with the host ports that were not specified
+// allocated from the engine's port range.  Without a port range docker allocates them.
+// Ports placed by a cluster already have their host ports so docker is only asked for
+// the ports in use when some are left to allocate.
+func (e *Engine) allocatePorts(binds []*Port) ([]*Port, error) {
+	used := []*Port{}
+
+	if e.PortRange != nil && dynamicPorts(binds) {
+		containers, err := e.ListContainers(false)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range containers {
+			used = append(used, c.Ports...)
+		}
+	}
+
+	ports, err := AllocatePorts(binds, used, e.PortRange)
+	if err != nil {
+		return nil, fmt.Errorf("%s on %s", err, e)
+	}
+
+	return ports, nil
+}
+
+func dynamicPorts(binds []*Port) bool {
+	for _, b := range binds {
+		if b.Port == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (e *Engine) ListImages() ([]string, error) {
 	images, err := e.client.ListImages()
 	if err != nil {
@@ -237,8 +388,15 @@ func (e *Engine) handler(ev *dockerclient.Event, args ...interface{}) {
 
 	container, err := FromDockerContainer(ev.Id, ev.From, e)
 	if err != nil {
-		// TODO: un fuck this shit, fuckin handler
-		return
+		// the container can no longer be inspected after it is destroyed so only
+		// the information from the event is available
+		container = &Container{
+			ID:     ev.Id,
+			Engine: e,
+			Image: &Image{
+				Name: ev.From,
+			},
+		}
 	}
 
 	event.Container = container
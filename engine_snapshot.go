@@ -4,19 +4,49 @@ type EngineSnapshot struct {
 	// ID is the engines id
 	ID string `json:"id,omitempty"`
 
+	// Labels are the labels of the engine
+	Labels []string `json:"labels,omitempty"`
+
+	// Version is the version of the engine's state that the snapshot was taken from
+	Version uint64 `json:"version,omitempty"`
+
 	Cpus float64 `json:"cpus,omitempty"`
 
 	Memory float64 `json:"memory,omitempty"`
 
+	// Disk is the disk space in MB of the engine
+	Disk float64 `json:"disk,omitempty"`
+
 	// ReservedCpus is the total amount of cpus that is reserved
 	ReservedCpus float64 `json:"reserved_cpus,omitempty"`
 
 	// ReservedMemory is the total amount of memory that is reserved
 	ReservedMemory float64 `json:"reserved_memory,omitempty"`
 
+	// ReservedDisk is the total amount of disk that is reserved
+	ReservedDisk float64 `json:"reserved_disk,omitempty"`
+
+	// PendingCpus is the amount of cpus reserved for containers that are not started yet
+	PendingCpus float64 `json:"pending_cpus,omitempty"`
+
+	// PendingMemory is the amount of memory reserved for containers that are not started yet
+	PendingMemory float64 `json:"pending_memory,omitempty"`
+
 	// CurrentMemory is the current system's used memory at the time of the snapshot
 	CurrentMemory float64 `json:"current_memory,omitempty"`
 
 	// CurrentCpu is the current system's cpu usage at the time of the snapshot
 	CurrentCpu float64 `json:"current_cpu,omitempty"`
+
+	// Replicas is the number of containers of the image being placed that run on the engine
+	Replicas int `json:"replicas,omitempty"`
+
+	// Preference is how strongly the engine is preferred by the scheduler between 0 and 1
+	Preference float64 `json:"preference,omitempty"`
+
+	// Reservations are the reservations of the containers running on the engine
+	Reservations []*Reservation `json:"reservations,omitempty"`
+
+	// Ports are the host ports held by the running and pending reservations on the engine
+	Ports []*Port `json:"ports,omitempty"`
 }
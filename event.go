@@ -2,11 +2,29 @@ package citadel
 
 import "time"
 
+const (
+	// RescheduledEvent is published with the new container when a container is moved
+	// off an engine that became unreachable
+	RescheduledEvent = "container_rescheduled"
+
+	// RescheduleFailedEvent is published with the lost container when it could not be
+	// placed on another engine
+	RescheduleFailedEvent = "container_reschedule_failed"
+
+	// PreemptedEvent is published with every container that is stopped to make room
+	// for a container of a higher priority
+	PreemptedEvent = "container_preempted"
+)
+
 type Event struct {
 	Type      string     `json:"type,omitempty"`
 	Container *Container `json:"container,omitempty"`
 	Engine    *Engine    `json:"engine,omitempty"`
 	Time      time.Time  `json:"time,omitempty"`
+
+	// Previous is the container that was replaced by Container for events that
+	// move containers between engines
+	Previous *Container `json:"previous,omitempty"`
 }
 
 type EventHandler interface {
@@ -13,6 +13,12 @@ type logHandler struct {
 }
 
 func (l *logHandler) Handle(e *citadel.Event) error {
+	if e.Container == nil {
+		log.Printf("type: %s time: %s engine: %s\n", e.Type, e.Time.Format(time.RubyDate), e.Engine.ID)
+
+		return nil
+	}
+
 	log.Printf("type: %s time: %s image: %s container: %s\n",
 		e.Type, e.Time.Format(time.RubyDate), e.Container.Image.Name, e.Container.ID)
 
@@ -32,7 +38,7 @@ func main() {
 		log.Fatal(err)
 	}
 
-	c, err := cluster.New(scheduler.NewResourceManager(), boot2docker)
+	c, err := cluster.New(scheduler.NewResourceManager(), nil, boot2docker)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,52 @@
+package citadel
+
+type (
+	// Explanation is the result of placing an image without starting it
+	Explanation struct {
+		Image *Image `json:"image,omitempty"`
+
+		// Engines are the decisions made for each engine of the cluster
+		Engines []*EngineExplanation `json:"engines,omitempty"`
+
+		// Chosen is the id of the engine that the image would be placed on
+		Chosen string `json:"chosen,omitempty"`
+
+		// Preempted are the reservations of the containers that would be stopped
+		// to make room for the image
+		Preempted []*Reservation `json:"preempted,omitempty"`
+
+		// Error is why the image can not be placed
+		Error string `json:"error,omitempty"`
+	}
+
+	// EngineExplanation is why an engine was accepted or rejected for an image
+	EngineExplanation struct {
+		Engine string      `json:"engine,omitempty"`
+		Health HealthState `json:"health,omitempty"`
+
+		// Accepted is true if every scheduler accepted the engine
+		Accepted bool `json:"accepted"`
+
+		// Decisions are the decisions of each scheduler
+		Decisions []*Decision `json:"decisions,omitempty"`
+
+		// Preference is the score of the engine's preferences between 0 and 1
+		Preference float64 `json:"preference,omitempty"`
+
+		// Resources is how full the engine would be with the image placed on it
+		Resources *ResourceScore `json:"resources,omitempty"`
+	}
+
+	// ResourceScore is how full an engine would be, in percent, with a container placed on it
+	ResourceScore struct {
+		Engine string  `json:"engine,omitempty"`
+		Cpus   float64 `json:"cpus"`
+		Memory float64 `json:"memory"`
+		Disk   float64 `json:"disk,omitempty"`
+		Total  float64 `json:"total"`
+
+		// Fits is true if the engine has the resources for the container
+		Fits   bool   `json:"fits"`
+		Reason string `json:"reason,omitempty"`
+	}
+)
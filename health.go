@@ -0,0 +1,50 @@
+package citadel
+
+import (
+	"time"
+)
+
+type HealthState string
+
+const (
+	// Healthy engines respond to the remote API in a timely manner
+	Healthy HealthState = "healthy"
+
+	// Degraded engines respond slowly or have recently failed to respond
+	Degraded HealthState = "degraded"
+
+	// Unreachable engines have failed to respond to consecutive health checks
+	Unreachable HealthState = "unreachable"
+)
+
+var (
+	// DegradedLatency is the response time of the remote API above which an
+	// engine is considered degraded
+	DegradedLatency = 2 * time.Second
+
+	// UnreachableFailures is the number of consecutive failed health checks after
+	// which an engine is considered unreachable
+	UnreachableFailures = 3
+)
+
+// Health is the result of the last health check of an engine
+type Health struct {
+	State HealthState `json:"state,omitempty"`
+
+	// Latency is the response time of the remote API
+	Latency time.Duration `json:"latency,omitempty"`
+
+	// Failures is the number of consecutive failed health checks
+	Failures int `json:"failures,omitempty"`
+
+	// Error is the error returned by the last failed health check
+	Error string `json:"error,omitempty"`
+
+	// Checked is the time of the last health check
+	Checked time.Time `json:"checked,omitempty"`
+}
+
+// HealthEventType returns the event type published when an engine changes to the state
+func HealthEventType(s HealthState) string {
+	return "engine_" + string(s)
+}
@@ -1,6 +1,9 @@
 package citadel
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // Image is a template for running a docker container
 type Image struct {
@@ -13,6 +16,9 @@ type Image struct {
 	// Memory is the amount of memory in MB for the container
 	Memory float64 `json:"memory,omitempty"`
 
+	// Disk is the amount of disk space in MB for the container
+	Disk float64 `json:"disk,omitempty"`
+
 	// Entrypoint is the entrypoint in the container
 	Entrypoint []string `json:"entrypoint,omitempty"`
 
@@ -31,21 +37,37 @@ type Image struct {
 	// Type is the container type, often service, batch, etc...
 	Type string `json:"type,omitempty"`
 
+	// Strategy is the placement strategy for the container: binpack, spread, random or
+	// spread:<label key>.  When empty the strategy for the type is used.
+	Strategy string `json:"strategy,omitempty"`
+
 	// Labels are matched with constraints on the engines
 	Labels []string `json:"labels,omitempty"`
 
+	// Preferences are constraints that engines are preferred to match but that
+	// do not prevent the container from running on other engines
+	Preferences []*Preference `json:"preferences,omitempty"`
+
 	// BindPorts ensures that the container has exclusive access to the specified ports
 	BindPorts []*Port `json:"bind_ports,omitempty"`
 
 	// UserData is user defined data that is passed to the container
 	UserData map[string][]string `json:"user_data,omitempty"`
 
-	// Volumes are volumes on the same engine
+	// Volumes are volumes on the same engine.  A volume bound from a name instead of a
+	// host path, name:/path, requires the named volume to be hosted on the engine
 	Volumes []string `json:"volumes,omitempty"`
 
 	// Links are mappings to other containers running on the same engine
 	Links map[string]string `json:"links,omitempty"`
 
+	// VolumesFrom are containers on the same engine whose volumes are mounted in the container
+	VolumesFrom []string `json:"volumes_from,omitempty"`
+
+	// Affinity are constraints on the containers running on the engine, container==<name>
+	// to run next to a container and image!=<image> to never run next to an image
+	Affinity []string `json:"affinity,omitempty"`
+
 	// RestartPolicy is the container restart policy if it exits
 	RestartPolicy RestartPolicy `json:"restart_policy,omitempty"`
 
@@ -57,6 +79,24 @@ type Image struct {
 
 	// ContainerName is the name set to the container
 	ContainerName string `json:"container_name,omitempty"`
+
+	// Service is the name of the service that the container belongs to
+	Service string `json:"service,omitempty"`
+
+	// Tenant is the team or namespace that the container belongs to and whose
+	// quota the container's resources count towards
+	Tenant string `json:"tenant,omitempty"`
+
+	// Priority of the container.  When preemption is enabled a container that does not
+	// fit on any engine stops running containers of a lower priority.
+	Priority int `json:"priority,omitempty"`
+}
+
+// Preference is a soft constraint on the engines with the weight of the constraint
+// relative to the other preferences of the image
+type Preference struct {
+	Constraint string  `json:"constraint,omitempty"`
+	Weight     float64 `json:"weight,omitempty"`
 }
 
 type RestartPolicy struct {
@@ -67,3 +107,43 @@ type RestartPolicy struct {
 func (i *Image) String() string {
 	return fmt.Sprintf("image %s type %s cpus %f memory %f", i.Name, i.Type, i.Cpus, i.Memory)
 }
+
+// NamedVolumes returns the names of the volumes that the image binds by name
+// rather than from a path on the host
+func (i *Image) NamedVolumes() []string {
+	out := []string{}
+
+	for _, v := range i.Volumes {
+		parts := strings.Split(v, ":")
+		if len(parts) < 2 || strings.HasPrefix(parts[0], "/") || strings.HasPrefix(parts[0], ".") {
+			continue
+		}
+
+		out = append(out, parts[0])
+	}
+
+	return out
+}
+
+// Key returns the name and tag of the image used to count replicas of the image
+func (i *Image) Key() string {
+	info := ParseImageName(i.Name)
+
+	return fmt.Sprintf("%s:%s", info.Name, info.Tag)
+}
+
+// Affinities returns the affinity constraints of the image including the constraints
+// to run on the same engine as the containers it links to or mounts volumes from
+func (i *Image) Affinities() []string {
+	out := append([]string{}, i.Affinity...)
+
+	for name := range i.Links {
+		out = append(out, fmt.Sprintf("container==%s", name))
+	}
+
+	for _, name := range i.VolumesFrom {
+		out = append(out, fmt.Sprintf("container==%s", name))
+	}
+
+	return out
+}
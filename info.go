@@ -4,10 +4,15 @@ type (
 	ClusterInfo struct {
 		Cpus           float64 `json:"cpus,omitempty"`
 		Memory         float64 `json:"memory,omitempty"`
+		Disk           float64 `json:"disk,omitempty"`
 		ContainerCount int     `json:"container_count,omitempty"`
 		EngineCount    int     `json:"engine_count,omitempty"`
 		ImageCount     int     `json:"image_count,omitempty"`
 		ReservedCpus   float64 `json:"reserved_cpus,omitempty"`
 		ReservedMemory float64 `json:"reserved_memory,omitempty"`
+		ReservedDisk   float64 `json:"reserved_disk,omitempty"`
+
+		// Tenants is the usage of each tenant that has containers or a quota
+		Tenants map[string]*TenantUsage `json:"tenants,omitempty"`
 	}
 )
@@ -0,0 +1,59 @@
+package citadel
+
+import (
+	"fmt"
+	"time"
+)
+
+type JobState string
+
+const (
+	// JobRunning jobs have an attempt running or are waiting to be retried
+	JobRunning JobState = "running"
+
+	// JobSucceeded jobs have an attempt that exited with code 0
+	JobSucceeded JobState = "succeeded"
+
+	// JobFailed jobs have failed more times than they are retried
+	JobFailed JobState = "failed"
+)
+
+// Job is a container that runs to completion and is started again if it fails
+type Job struct {
+	// ID is the unique id of the job
+	ID string `json:"id,omitempty"`
+
+	// Image is the configuration from which the containers of the job are created
+	Image *Image `json:"image,omitempty"`
+
+	// Retries is the number of times the job is started again after failing
+	Retries int `json:"retries,omitempty"`
+
+	State JobState `json:"state,omitempty"`
+
+	// ExitCode is the exit code of the last attempt that finished
+	ExitCode int `json:"exit_code"`
+
+	// Error is the reason a job failed without an exit code
+	Error string `json:"error,omitempty"`
+
+	// Attempts are the containers started for the job
+	Attempts []*JobAttempt `json:"attempts,omitempty"`
+
+	Started  time.Time `json:"started,omitempty"`
+	Finished time.Time `json:"finished,omitempty"`
+}
+
+// JobAttempt is a container started for a job
+type JobAttempt struct {
+	ContainerID string    `json:"container_id,omitempty"`
+	EngineID    string    `json:"engine_id,omitempty"`
+	ExitCode    int       `json:"exit_code"`
+	Error       string    `json:"error,omitempty"`
+	Started     time.Time `json:"started,omitempty"`
+	Finished    time.Time `json:"finished,omitempty"`
+}
+
+func (j *Job) String() string {
+	return fmt.Sprintf("job %s image %s state %s attempts %d", j.ID, j.Image.Name, j.State, len(j.Attempts))
+}
@@ -1,8 +1,112 @@
 package citadel
 
+import "fmt"
+
 type Port struct {
 	Proto         string `json:"proto,omitempty"`
 	HostIp        string `json:"host_ip,omitempty"`
 	Port          int    `json:"port,omitempty"`
 	ContainerPort int    `json:"container_port,omitempty"`
 }
+
+// PortRange is an inclusive range of host ports
+type PortRange struct {
+	Start int `json:"start,omitempty"`
+	End   int `json:"end,omitempty"`
+}
+
+// Conflicts returns true if both ports bind the same host port for the same protocol
+// on overlapping host ips.  A port without a host port never conflicts.
+func (p *Port) Conflicts(o *Port) bool {
+	if p.Port == 0 || p.Port != o.Port || protocol(p.Proto) != protocol(o.Proto) {
+		return false
+	}
+
+	return anyHostIp(p.HostIp) || anyHostIp(o.HostIp) || p.HostIp == o.HostIp
+}
+
+// Free returns the ports in the range that do not conflict with the used ports
+// when bound for the protocol on the host ip
+func (r *PortRange) Free(used []*Port, proto, hostIp string) []int {
+	out := []int{}
+
+	for port := r.Start; port <= r.End; port++ {
+		candidate := &Port{Proto: proto, HostIp: hostIp, Port: port}
+
+		free := true
+		for _, u := range used {
+			if candidate.Conflicts(u) {
+				free = false
+				break
+			}
+		}
+
+		if free {
+			out = append(out, port)
+		}
+	}
+
+	return out
+}
+
+// AllocatePorts returns a copy of the binds with the host ports that were not specified
+// allocated from the range.  An error is returned if a bind conflicts with the used ports
+// or the range has no free port left.  Without a range docker allocates the host ports.
+func AllocatePorts(binds, used []*Port, r *PortRange) ([]*Port, error) {
+	var (
+		out     = []*Port{}
+		dynamic = []*Port{}
+	)
+	used = append([]*Port{}, used...)
+
+	for _, b := range binds {
+		p := *b
+		out = append(out, &p)
+
+		if p.Port == 0 {
+			dynamic = append(dynamic, &p)
+
+			continue
+		}
+
+		for _, u := range used {
+			if p.Conflicts(u) {
+				return nil, fmt.Errorf("port %d/%s is already bound", p.Port, protocol(p.Proto))
+			}
+		}
+
+		used = append(used, &p)
+	}
+
+	if r == nil {
+		return out, nil
+	}
+
+	for _, p := range dynamic {
+		free := r.Free(used, p.Proto, p.HostIp)
+		if len(free) == 0 {
+			return nil, fmt.Errorf("no free %s ports in range %s", protocol(p.Proto), r)
+		}
+
+		p.Port = free[0]
+		used = append(used, p)
+	}
+
+	return out, nil
+}
+
+func (r *PortRange) String() string {
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+func protocol(proto string) string {
+	if proto == "" {
+		return "tcp"
+	}
+
+	return proto
+}
+
+func anyHostIp(ip string) bool {
+	return ip == "" || ip == "0.0.0.0"
+}
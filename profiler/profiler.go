@@ -0,0 +1,304 @@
+package profiler
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/citadel/citadel"
+)
+
+// DefaultSamples is the number of samples kept for each image
+const DefaultSamples = 1000
+
+// MinSamples is the number of samples required before a profile is used to
+// estimate the resources of an image
+const MinSamples = 10
+
+// Profiler records the observed resource usage of containers by image so that the
+// resources requested for an image can be compared with what it actually uses
+type Profiler struct {
+	mux sync.Mutex
+
+	samples int
+	images  map[string]*history
+
+	// collected is the time of the last usage collected from each engine
+	collected map[string]time.Time
+}
+
+// history is a fixed size window of samples for an image
+type history struct {
+	image           string
+	requestedCpus   float64
+	requestedMemory float64
+	cpus            []float64
+	memory          []float64
+	nextCpu         int
+	nextMemory      int
+}
+
+// Percentiles of the observed usage of a resource
+type Percentiles struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// Profile is the observed usage of an image
+type Profile struct {
+	// Image is the name and tag of the image
+	Image string `json:"image,omitempty"`
+
+	// Samples is the number of memory samples the profile is based on
+	Samples int `json:"samples,omitempty"`
+
+	// CpuSamples is the number of cpu samples, which is lower than Samples because the
+	// cpu usage is unknown for the first sample of a container
+	CpuSamples int `json:"cpu_samples,omitempty"`
+
+	// RequestedCpus is the number of cpus last requested for the image
+	RequestedCpus float64 `json:"requested_cpus,omitempty"`
+
+	// RequestedMemory is the memory last requested for the image
+	RequestedMemory float64 `json:"requested_memory,omitempty"`
+
+	Cpus   *Percentiles `json:"cpus,omitempty"`
+	Memory *Percentiles `json:"memory,omitempty"`
+}
+
+// Mismatch is an image that requests a resource that is too far from the p95
+// of its observed usage
+type Mismatch struct {
+	Image     string  `json:"image,omitempty"`
+	Resource  string  `json:"resource,omitempty"`
+	Requested float64 `json:"requested,omitempty"`
+	P95       float64 `json:"p95,omitempty"`
+
+	// Over is true if the image requests more than it uses and false if it
+	// uses more than it requests
+	Over bool `json:"over,omitempty"`
+}
+
+// New returns a profiler that keeps up to samples samples per image
+func New(samples int) *Profiler {
+	if samples <= 0 {
+		samples = DefaultSamples
+	}
+
+	return &Profiler{
+		samples:   samples,
+		images:    make(map[string]*history),
+		collected: make(map[string]time.Time),
+	}
+}
+
+// Collect records the last usage sampled for each of the engines that has not
+// already been recorded
+func (p *Profiler) Collect(engines []*citadel.Engine) {
+	for _, e := range engines {
+		u := e.Usage()
+		if u == nil {
+			continue
+		}
+
+		p.mux.Lock()
+		if !u.Time.After(p.collected[e.ID]) {
+			p.mux.Unlock()
+			continue
+		}
+		p.collected[e.ID] = u.Time
+		p.mux.Unlock()
+
+		for _, cu := range u.Containers {
+			p.Record(cu)
+		}
+	}
+}
+
+// Record adds the usage of a container to the profile of its image
+func (p *Profiler) Record(u *citadel.ContainerUsage) {
+	image := u.Container.Image.Key()
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	h := p.images[image]
+	if h == nil {
+		h = &history{image: image}
+		p.images[image] = h
+	}
+
+	h.requestedCpus = u.Container.Image.Cpus
+	h.requestedMemory = u.Container.Image.Memory
+
+	// the cpu usage is unknown for the first sample of a container
+	if u.Interval > 0 {
+		h.cpus, h.nextCpu = add(h.cpus, h.nextCpu, u.Cpus, p.samples)
+	}
+
+	h.memory, h.nextMemory = add(h.memory, h.nextMemory, u.Memory, p.samples)
+}
+
+// Profile returns the profile of the image or nil if the image has not been observed
+func (p *Profiler) Profile(image string) *Profile {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	h := p.images[(&citadel.Image{Name: image}).Key()]
+	if h == nil {
+		return nil
+	}
+
+	return h.profile()
+}
+
+// Profiles returns the profiles of all the observed images
+func (p *Profiler) Profiles() []*Profile {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	out := []*Profile{}
+	for _, h := range p.images {
+		out = append(out, h.profile())
+	}
+
+	sort.Sort(profiles(out))
+
+	return out
+}
+
+// Report returns the images whose requested cpus or memory differ from the p95 of
+// their observed usage by more than tolerance, a fraction of the p95.  Images without
+// enough samples are not reported.
+func (p *Profiler) Report(tolerance float64) []*Mismatch {
+	out := []*Mismatch{}
+
+	for _, profile := range p.Profiles() {
+		if profile.Samples < MinSamples {
+			continue
+		}
+
+		if profile.CpuSamples >= MinSamples {
+			if m := mismatch(profile.Image, "cpus", profile.RequestedCpus, profile.Cpus, tolerance); m != nil {
+				out = append(out, m)
+			}
+		}
+
+		if m := mismatch(profile.Image, "memory", profile.RequestedMemory, profile.Memory, tolerance); m != nil {
+			out = append(out, m)
+		}
+	}
+
+	return out
+}
+
+// Estimate returns the p95 of the observed cpus and memory of the image.  False is
+// returned if the image does not have enough samples to be estimated.  The cpus are
+// 0, leaving the requested cpus unchanged, until there are enough cpu samples.
+func (p *Profiler) Estimate(i *citadel.Image) (float64, float64, bool) {
+	profile := p.Profile(i.Name)
+	if profile == nil || profile.Samples < MinSamples {
+		return 0, 0, false
+	}
+
+	var cpus float64
+	if profile.Cpus != nil && profile.CpuSamples >= MinSamples {
+		cpus = profile.Cpus.P95
+	}
+
+	return cpus, profile.Memory.P95, true
+}
+
+func (h *history) profile() *Profile {
+	return &Profile{
+		Image:           h.image,
+		Samples:         len(h.memory),
+		CpuSamples:      len(h.cpus),
+		RequestedCpus:   h.requestedCpus,
+		RequestedMemory: h.requestedMemory,
+		Cpus:            percentiles(h.cpus),
+		Memory:          percentiles(h.memory),
+	}
+}
+
+func mismatch(image, resource string, requested float64, p *Percentiles, tolerance float64) *Mismatch {
+	if p == nil || requested == 0 {
+		return nil
+	}
+
+	m := &Mismatch{
+		Image:     image,
+		Resource:  resource,
+		Requested: requested,
+		P95:       p.P95,
+	}
+
+	switch {
+	case requested > p.P95*(1+tolerance):
+		m.Over = true
+	case requested < p.P95*(1-tolerance):
+		m.Over = false
+	default:
+		return nil
+	}
+
+	return m
+}
+
+// add inserts the value into the fixed size window returning the window and
+// the position of the next insert
+func add(window []float64, next int, v float64, size int) ([]float64, int) {
+	if len(window) < size {
+		return append(window, v), 0
+	}
+
+	window[next] = v
+
+	return window, (next + 1) % size
+}
+
+// percentiles returns the nearest rank percentiles of the values
+func percentiles(values []float64) *Percentiles {
+	if len(values) == 0 {
+		return nil
+	}
+
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+
+	rank := func(p float64) float64 {
+		i := int(p*float64(len(sorted))+0.5) - 1
+		if i < 0 {
+			i = 0
+		}
+
+		if i >= len(sorted) {
+			i = len(sorted) - 1
+		}
+
+		return sorted[i]
+	}
+
+	return &Percentiles{
+		P50: rank(0.50),
+		P95: rank(0.95),
+		P99: rank(0.99),
+		Max: sorted[len(sorted)-1],
+	}
+}
+
+type profiles []*Profile
+
+func (p profiles) Len() int {
+	return len(p)
+}
+
+func (p profiles) Swap(i, j int) {
+	p[i], p[j] = p[j], p[i]
+}
+
+func (p profiles) Less(i, j int) bool {
+	return p[i].Image < p[j].Image
+}
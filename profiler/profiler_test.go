@@ -0,0 +1,110 @@
+package profiler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/citadel/citadel"
+)
+
+func record(p *Profiler, image *citadel.Image, cpus, memory float64) {
+	p.Record(&citadel.ContainerUsage{
+		Container: &citadel.Container{Image: image},
+		Cpus:      cpus,
+		Memory:    memory,
+		Interval:  time.Second,
+	})
+}
+
+func TestProfilePercentiles(t *testing.T) {
+	p := New(100)
+	image := &citadel.Image{Name: "redis", Cpus: 1, Memory: 512}
+
+	for i := 1; i <= 100; i++ {
+		record(p, image, float64(i)/100, float64(i))
+	}
+
+	profile := p.Profile("redis:latest")
+	if profile == nil {
+		t.Fatal("expected profile for redis:latest")
+	}
+
+	if profile.Memory.P95 != 95 {
+		t.Fatalf("expected memory p95 of 95; received %f", profile.Memory.P95)
+	}
+
+	if profile.Cpus.Max != 1 {
+		t.Fatalf("expected cpus max of 1; received %f", profile.Cpus.Max)
+	}
+}
+
+func TestProfileWindow(t *testing.T) {
+	p := New(10)
+	image := &citadel.Image{Name: "redis:2.8"}
+
+	for i := 0; i < 25; i++ {
+		record(p, image, 0, float64(i))
+	}
+
+	profile := p.Profile("redis:2.8")
+	if profile.Samples != 10 {
+		t.Fatalf("expected 10 samples; received %d", profile.Samples)
+	}
+
+	if profile.Memory.P50 < 15 {
+		t.Fatalf("expected old samples to be dropped; received p50 %f", profile.Memory.P50)
+	}
+}
+
+func TestReport(t *testing.T) {
+	p := New(100)
+
+	over := &citadel.Image{Name: "over", Cpus: 2, Memory: 1024}
+	under := &citadel.Image{Name: "under", Cpus: 0.5, Memory: 128}
+
+	for i := 0; i < MinSamples; i++ {
+		record(p, over, 0.5, 256)
+		record(p, under, 0.5, 512)
+	}
+
+	report := p.Report(0.2)
+	if len(report) != 3 {
+		t.Fatalf("expected 3 mismatches; received %d", len(report))
+	}
+
+	for _, m := range report {
+		if m.Image == "over:latest" && !m.Over {
+			t.Fatalf("expected %s %s to be over requested", m.Image, m.Resource)
+		}
+
+		if m.Image == "under:latest" && (m.Over || m.Resource != "memory") {
+			t.Fatalf("expected only under:latest memory to be under requested; received %s", m.Resource)
+		}
+	}
+}
+
+func TestEstimateWithoutCpuSamples(t *testing.T) {
+	p := New(100)
+	image := &citadel.Image{Name: "redis", Cpus: 1, Memory: 512}
+
+	// the first sample of a container has no cpu usage
+	for i := 0; i < MinSamples; i++ {
+		p.Record(&citadel.ContainerUsage{
+			Container: &citadel.Container{Image: image},
+			Memory:    256,
+		})
+	}
+
+	cpus, memory, ok := p.Estimate(image)
+	if !ok {
+		t.Fatal("expected an estimate from the memory samples")
+	}
+
+	if cpus != 0 || memory != 256 {
+		t.Fatalf("expected only the memory to be estimated; received cpus %f memory %f", cpus, memory)
+	}
+
+	if report := p.Report(0.2); len(report) != 1 || report[0].Resource != "memory" {
+		t.Fatalf("expected only a memory mismatch; received %d mismatches", len(report))
+	}
+}
@@ -0,0 +1,45 @@
+package citadel
+
+import "fmt"
+
+type (
+	// Quota is the most resources that the containers of a tenant are allowed to reserve.
+	// Limits that are 0 are not enforced.
+	Quota struct {
+		Cpus       float64 `json:"cpus,omitempty"`
+		Memory     float64 `json:"memory,omitempty"`
+		Containers int     `json:"containers,omitempty"`
+	}
+
+	// TenantUsage is the resources reserved by the containers of a tenant
+	TenantUsage struct {
+		Cpus       float64 `json:"cpus,omitempty"`
+		Memory     float64 `json:"memory,omitempty"`
+		Containers int     `json:"containers,omitempty"`
+		Quota      *Quota  `json:"quota,omitempty"`
+	}
+
+	// QuotaError is returned when starting a container would exceed the quota of its tenant
+	QuotaError struct {
+		Tenant   string
+		Resource string
+	}
+)
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("tenant %s would exceed its %s quota", e.Tenant, e.Resource)
+}
+
+// Exceeded returns a QuotaError if the usage is over any of the limits of the quota
+func (q *Quota) Exceeded(tenant string, u *TenantUsage) error {
+	switch {
+	case q.Cpus > 0 && u.Cpus > q.Cpus:
+		return &QuotaError{Tenant: tenant, Resource: "cpus"}
+	case q.Memory > 0 && u.Memory > q.Memory:
+		return &QuotaError{Tenant: tenant, Resource: "memory"}
+	case q.Containers > 0 && u.Containers > q.Containers:
+		return &QuotaError{Tenant: tenant, Resource: "containers"}
+	}
+
+	return nil
+}
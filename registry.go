@@ -0,0 +1,32 @@
+package citadel
+
+// Registry stores the state of the cluster so that the cluster manager is able
+// to pick up where it left off after a restart
+type Registry interface {
+	// FetchEngines returns all the engines saved in the registry
+	FetchEngines() ([]*Engine, error)
+
+	// SaveEngine adds or updates the engine in the registry
+	SaveEngine(*Engine) error
+
+	// DeleteEngine removes the engine and all of its reservations from the registry
+	DeleteEngine(*Engine) error
+
+	// FetchReservations returns all the reservations saved in the registry
+	FetchReservations() ([]*Reservation, error)
+
+	// SaveReservation adds or updates the reservation in the registry
+	SaveReservation(*Reservation) error
+
+	// DeleteReservation removes the reservation from the registry
+	DeleteReservation(*Reservation) error
+
+	// FetchServices returns all the services saved in the registry
+	FetchServices() ([]*Service, error)
+
+	// SaveService adds or updates the service in the registry
+	SaveService(*Service) error
+
+	// DeleteService removes the service from the registry
+	DeleteService(*Service) error
+}
@@ -0,0 +1,193 @@
+package registry
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/citadel/citadel"
+)
+
+// FileRegistry keeps the cluster state in memory and writes it to a json file
+// on every change so that it survives a restart
+type FileRegistry struct {
+	mux sync.Mutex
+
+	path string
+
+	// state is only replaced once it is written to disk
+	stateMux sync.Mutex
+	state    *MemoryRegistry
+}
+
+type fileState struct {
+	Engines      []*citadel.Engine      `json:"engines,omitempty"`
+	Reservations []*citadel.Reservation `json:"reservations,omitempty"`
+	Services     []*citadel.Service     `json:"services,omitempty"`
+}
+
+// NewFileRegistry returns a registry backed by the file at path, loading any
+// state that was previously written to it
+func NewFileRegistry(path string) (*FileRegistry, error) {
+	f := &FileRegistry{
+		path:  path,
+		state: NewMemoryRegistry(),
+	}
+
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (f *FileRegistry) FetchEngines() ([]*citadel.Engine, error) {
+	return f.current().FetchEngines()
+}
+
+func (f *FileRegistry) SaveEngine(e *citadel.Engine) error {
+	return f.update(func(state *MemoryRegistry) error {
+		return state.SaveEngine(e)
+	})
+}
+
+func (f *FileRegistry) DeleteEngine(e *citadel.Engine) error {
+	return f.update(func(state *MemoryRegistry) error {
+		return state.DeleteEngine(e)
+	})
+}
+
+func (f *FileRegistry) FetchReservations() ([]*citadel.Reservation, error) {
+	return f.current().FetchReservations()
+}
+
+func (f *FileRegistry) SaveReservation(r *citadel.Reservation) error {
+	return f.update(func(state *MemoryRegistry) error {
+		return state.SaveReservation(r)
+	})
+}
+
+func (f *FileRegistry) DeleteReservation(r *citadel.Reservation) error {
+	return f.update(func(state *MemoryRegistry) error {
+		return state.DeleteReservation(r)
+	})
+}
+
+func (f *FileRegistry) FetchServices() ([]*citadel.Service, error) {
+	return f.current().FetchServices()
+}
+
+func (f *FileRegistry) SaveService(s *citadel.Service) error {
+	return f.update(func(state *MemoryRegistry) error {
+		return state.SaveService(s)
+	})
+}
+
+func (f *FileRegistry) DeleteService(s *citadel.Service) error {
+	return f.update(func(state *MemoryRegistry) error {
+		return state.DeleteService(s)
+	})
+}
+
+func (f *FileRegistry) load() error {
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	var s *fileState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	if s == nil {
+		return nil
+	}
+
+	for _, e := range s.Engines {
+		f.state.SaveEngine(e)
+	}
+
+	for _, r := range s.Reservations {
+		f.state.SaveReservation(r)
+	}
+
+	for _, svc := range s.Services {
+		f.state.SaveService(svc)
+	}
+
+	return nil
+}
+
+func (f *FileRegistry) current() *MemoryRegistry {
+	f.stateMux.Lock()
+	defer f.stateMux.Unlock()
+
+	return f.state
+}
+
+// update applies fn to a copy of the in memory state and writes the full state to
+// disk, replacing the old file atomically.  The copy replaces the in memory state only
+// when it was written so that a failed write leaves both unchanged.
+func (f *FileRegistry) update(fn func(*MemoryRegistry) error) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	state := f.current().clone()
+
+	if err := fn(state); err != nil {
+		return err
+	}
+
+	if err := f.write(state); err != nil {
+		return err
+	}
+
+	f.stateMux.Lock()
+	f.state = state
+	f.stateMux.Unlock()
+
+	return nil
+}
+
+// write writes the state to a temporary file and renames it over the registry's file
+func (f *FileRegistry) write(state *MemoryRegistry) error {
+	engines, _ := state.FetchEngines()
+	reservations, _ := state.FetchReservations()
+	services, _ := state.FetchServices()
+
+	data, err := json.MarshalIndent(&fileState{
+		Engines:      engines,
+		Reservations: reservations,
+		Services:     services,
+	}, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), ".citadel-registry")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path)
+}
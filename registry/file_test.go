@@ -0,0 +1,111 @@
+package registry
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/citadel/citadel"
+)
+
+func TestFileRegistryReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "citadel-registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.json")
+
+	r, err := NewFileRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine := &citadel.Engine{ID: "local", Addr: "http://127.0.0.1:2375", Cpus: 4, Memory: 2048}
+	if err := r.SaveEngine(engine); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.SaveReservation(&citadel.Reservation{ContainerID: "abc", EngineID: "local", Cpus: 1, Memory: 512}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.SaveService(&citadel.Service{Name: "web", Image: &citadel.Image{Name: "nginx"}, Replicas: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewFileRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engines, err := reloaded.FetchEngines()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(engines) != 1 || engines[0].ID != "local" {
+		t.Fatalf("expected engine local to be reloaded; received %v", engines)
+	}
+
+	services, err := reloaded.FetchServices()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(services) != 1 || services[0].Name != "web" || services[0].Replicas != 3 {
+		t.Fatalf("expected service web to be reloaded; received %v", services)
+	}
+
+	if err := reloaded.DeleteEngine(engine); err != nil {
+		t.Fatal(err)
+	}
+
+	reservations, err := reloaded.FetchReservations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reservations) != 0 {
+		t.Fatalf("expected reservations to be removed with the engine; received %d", len(reservations))
+	}
+}
+
+func TestFileRegistryFailedWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "citadel-registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.json")
+
+	r, err := NewFileRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.SaveEngine(&citadel.Engine{ID: "local"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the temporary file can not be created once the directory is gone
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.SaveEngine(&citadel.Engine{ID: "remote"}); err == nil {
+		t.Fatalf("expected the write of the registry to fail")
+	}
+
+	engines, err := r.FetchEngines()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(engines) != 1 || engines[0].ID != "local" {
+		t.Fatalf("expected only engine local after the failed write; received %v", engines)
+	}
+}
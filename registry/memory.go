@@ -0,0 +1,143 @@
+package registry
+
+import (
+	"sync"
+
+	"github.com/citadel/citadel"
+)
+
+// MemoryRegistry keeps the cluster state in memory and is lost when the
+// process exits
+type MemoryRegistry struct {
+	mux sync.Mutex
+
+	engines      map[string]*citadel.Engine
+	reservations map[string]*citadel.Reservation
+	services     map[string]*citadel.Service
+}
+
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{
+		engines:      make(map[string]*citadel.Engine),
+		reservations: make(map[string]*citadel.Reservation),
+		services:     make(map[string]*citadel.Service),
+	}
+}
+
+// clone returns a copy of the registry that can be changed without changing the registry
+func (m *MemoryRegistry) clone() *MemoryRegistry {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	out := NewMemoryRegistry()
+
+	for id, e := range m.engines {
+		out.engines[id] = e
+	}
+
+	for id, r := range m.reservations {
+		out.reservations[id] = r
+	}
+
+	for name, s := range m.services {
+		out.services[name] = s
+	}
+
+	return out
+}
+
+func (m *MemoryRegistry) FetchEngines() ([]*citadel.Engine, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	out := []*citadel.Engine{}
+	for _, e := range m.engines {
+		out = append(out, e)
+	}
+
+	return out, nil
+}
+
+func (m *MemoryRegistry) SaveEngine(e *citadel.Engine) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.engines[e.ID] = e
+
+	return nil
+}
+
+func (m *MemoryRegistry) DeleteEngine(e *citadel.Engine) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	delete(m.engines, e.ID)
+
+	for id, r := range m.reservations {
+		if r.EngineID == e.ID {
+			delete(m.reservations, id)
+		}
+	}
+
+	return nil
+}
+
+func (m *MemoryRegistry) FetchReservations() ([]*citadel.Reservation, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	out := []*citadel.Reservation{}
+	for _, r := range m.reservations {
+		out = append(out, r)
+	}
+
+	return out, nil
+}
+
+func (m *MemoryRegistry) SaveReservation(r *citadel.Reservation) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.reservations[r.ContainerID] = r
+
+	return nil
+}
+
+func (m *MemoryRegistry) DeleteReservation(r *citadel.Reservation) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	delete(m.reservations, r.ContainerID)
+
+	return nil
+}
+
+func (m *MemoryRegistry) FetchServices() ([]*citadel.Service, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	out := []*citadel.Service{}
+	for _, s := range m.services {
+		out = append(out, s)
+	}
+
+	return out, nil
+}
+
+func (m *MemoryRegistry) SaveService(s *citadel.Service) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.services[s.Name] = s
+
+	return nil
+}
+
+func (m *MemoryRegistry) DeleteService(s *citadel.Service) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	delete(m.services, s.Name)
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package citadel
+
+import (
+	"fmt"
+	"time"
+)
+
+// Reservation is the placement of a container on an engine along with the
+// resources that the container holds on that engine
+type Reservation struct {
+	// ContainerID is the id of the container holding the reservation
+	ContainerID string `json:"container_id,omitempty"`
+
+	// EngineID is the id of the engine where the container was placed
+	EngineID string `json:"engine_id,omitempty"`
+
+	// Image is the configuration from which the container was created
+	Image *Image `json:"image,omitempty"`
+
+	// Cpus is the number of cpus reserved on the engine
+	Cpus float64 `json:"cpus,omitempty"`
+
+	// Memory is the amount of memory in MB reserved on the engine
+	Memory float64 `json:"memory,omitempty"`
+
+	// Disk is the disk space in MB reserved on the engine
+	Disk float64 `json:"disk,omitempty"`
+
+	// Ports are the host ports bound by the container on the engine
+	Ports []*Port `json:"ports,omitempty"`
+
+	// Time is when the reservation was made
+	Time time.Time `json:"time,omitempty"`
+}
+
+func (r *Reservation) String() string {
+	return fmt.Sprintf("reservation container %s engine %s cpus %f memory %f", r.ContainerID, r.EngineID, r.Cpus, r.Memory)
+}
@@ -1,12 +1,123 @@
 package citadel
 
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrNoResources is returned by a ResourceManager when none of the engines have
+	// the resources to run the container
+	ErrNoResources = errors.New("no resources available to schedule container")
+)
+
 // Scheduler is able to return a yes or know decision on if the specified Engine is
 // able to run the specified image
 type Scheduler interface {
-	// Schedule returns true if the engine can run the specified image
-	Schedule(*Image, *Engine) (bool, error)
+	// Schedule returns the decision of the scheduler on whether the engine can run the
+	// specified image.  An error is returned if the scheduler is unable to decide.
+	Schedule(*Image, *Engine) (*Decision, error)
+}
+
+// Decision is a scheduler's decision on whether an engine is able to run an image
+type Decision struct {
+	Accepted bool `json:"accepted"`
+
+	// Reason is why the engine was rejected
+	Reason string `json:"reason,omitempty"`
+
+	// Scheduler is the name of the scheduler that made the decision
+	Scheduler string `json:"scheduler,omitempty"`
+}
+
+// Accept returns the decision of the scheduler to accept an engine
+func Accept(scheduler string) *Decision {
+	return &Decision{Accepted: true, Scheduler: scheduler}
+}
+
+// Reject returns the decision of the scheduler to reject an engine with the formatted reason
+func Reject(scheduler, format string, args ...interface{}) *Decision {
+	return &Decision{Scheduler: scheduler, Reason: fmt.Sprintf(format, args...)}
+}
+
+func (d *Decision) String() string {
+	if d.Accepted {
+		return fmt.Sprintf("accepted by %s", d.Scheduler)
+	}
+
+	return fmt.Sprintf("rejected by %s: %s", d.Scheduler, d.Reason)
+}
+
+// UnschedulableError is returned when none of the engines are able to run an image
+type UnschedulableError struct {
+	Image *Image
+
+	// Decisions are the rejections of each engine by engine id
+	Decisions map[string]*Decision
+}
+
+func (e *UnschedulableError) Error() string {
+	ids := []string{}
+	for id := range e.Decisions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	reasons := []string{}
+	for _, id := range ids {
+		reasons = append(reasons, fmt.Sprintf("%s %s", id, e.Decisions[id]))
+	}
+
+	return fmt.Sprintf("no eligible engines to run image: %s", strings.Join(reasons, "; "))
+}
+
+// Scorer returns how strongly the specified Engine is preferred to run the specified
+// image, as opposed to the hard yes or no decision of a Scheduler
+type Scorer interface {
+	// Score returns the preference for the engine between 0 and 1
+	Score(*Image, *Engine) (float64, error)
 }
 
 type ResourceManager interface {
 	PlaceContainer(*Container, []*EngineSnapshot) (*EngineSnapshot, error)
 }
+
+// SchedulerGroup is a Scheduler made of other schedulers whose decisions can be
+// explained individually
+type SchedulerGroup interface {
+	Schedulers() []Scheduler
+}
+
+// ExclusiveScheduler is a Scheduler that never accepts an engine already running the
+// image, so containers of the image placed together must also be on different engines
+type ExclusiveScheduler interface {
+	Exclusive(*Image) bool
+}
+
+// Estimator is a ResourceManager that reserves the cpus and memory an image is estimated
+// to use instead of the resources it requests.  Cpus or memory of 0 are not estimated.
+type Estimator interface {
+	Estimate(*Image) (float64, float64, bool)
+}
+
+// ResourceScorer is a ResourceManager that reports how full each engine would be with
+// the container placed on it
+type ResourceScorer interface {
+	Scores(*Container, []*EngineSnapshot) []*ResourceScore
+}
+
+// Allocator shares the cluster between the tenants of the images waiting to be placed
+type Allocator interface {
+	// Order returns the indexes of the images in the order they should be placed
+	Order(*ClusterInfo, []*Image) []int
+}
+
+// Preemptor is a ResourceManager that is able to make room for a container that does not
+// fit on any engine by stopping running containers of a lower priority
+type Preemptor interface {
+	// Preempt returns the engine to run the container on and the reservations of the
+	// containers to stop on that engine
+	Preempt(*Container, []*EngineSnapshot) (*EngineSnapshot, []*Reservation, error)
+}
@@ -0,0 +1,70 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/citadel/citadel"
+)
+
+// AffinityScheduler only returns engines whose running containers satisfy the affinity
+// constraints of the image.  The key container matches container names and the key
+// image matches image names with or without their tag, for example container==db
+// requires a container named db on the engine and image!=redis forbids any redis
+// container on the engine.  Linked containers and containers that volumes are mounted
+// from are required to be on the same engine.
+type AffinityScheduler struct {
+}
+
+func (a *AffinityScheduler) Schedule(c *citadel.Image, e *citadel.Engine) (*citadel.Decision, error) {
+	affinities := c.Affinities()
+	if len(affinities) == 0 {
+		return citadel.Accept("affinity"), nil
+	}
+
+	constraints, err := ParseConstraints(affinities)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, con := range constraints {
+		if (con.Key != "container" && con.Key != "image") || con.Operator == opLabel {
+			return nil, fmt.Errorf("invalid affinity %q: affinities are constraints on container or image", con)
+		}
+	}
+
+	containers, err := e.ListContainers(false)
+	if err != nil {
+		return nil, err
+	}
+
+	names, images := a.values(containers)
+
+	for _, con := range constraints {
+		values := names
+		if con.Key == "image" {
+			values = images
+		}
+
+		if !con.MatchValues(values) {
+			return citadel.Reject("affinity", "engine does not satisfy %q", con), nil
+		}
+	}
+
+	return citadel.Accept("affinity"), nil
+}
+
+// values returns the names and the images of the containers
+func (a *AffinityScheduler) values(containers []*citadel.Container) ([]string, []string) {
+	names := []string{}
+	images := []string{}
+
+	for _, c := range containers {
+		names = append(names, strings.TrimPrefix(c.Name, "/"))
+
+		info := citadel.ParseImageName(c.Image.Name)
+		images = append(images, c.Image.Name, info.Name, c.Image.Key())
+	}
+
+	return names, images
+}
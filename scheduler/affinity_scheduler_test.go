@@ -0,0 +1,61 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/citadel/citadel"
+)
+
+func TestAffinityMatch(t *testing.T) {
+	var (
+		a          = &AffinityScheduler{}
+		containers = []*citadel.Container{
+			{Name: "/db", Image: &citadel.Image{Name: "postgres:9.3"}},
+			{Name: "/cache", Image: &citadel.Image{Name: "redis"}},
+		}
+		names, images = a.values(containers)
+	)
+
+	tests := []struct {
+		affinity string
+		expected bool
+	}{
+		{"container==db", true},
+		{"container==web", false},
+		{"container!=db", false},
+		{"image!=redis", false},
+		{"image!=redis:latest", false},
+		{"image!=redis:2.8", true},
+		{"image==postgres", true},
+		{"image!=mysql", true},
+	}
+
+	for _, test := range tests {
+		con, err := ParseConstraint(test.affinity)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		values := names
+		if con.Key == "image" {
+			values = images
+		}
+
+		if actual := con.MatchValues(values); actual != test.expected {
+			t.Fatalf("%s: expected %v; received %v", test.affinity, test.expected, actual)
+		}
+	}
+}
+
+func TestImageAffinities(t *testing.T) {
+	image := &citadel.Image{
+		Affinity:    []string{"image!=redis"},
+		Links:       map[string]string{"db": "database"},
+		VolumesFrom: []string{"data"},
+	}
+
+	affinities := image.Affinities()
+	if len(affinities) != 3 || affinities[1] != "container==db" || affinities[2] != "container==data" {
+		t.Fatalf("expected links and volumes from as affinities; received %v", affinities)
+	}
+}
@@ -0,0 +1,276 @@
+package scheduler
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/citadel/citadel"
+)
+
+// Constraint is a condition on the labels of an engine.  Engine labels in the form
+// key=value are matched by key while other labels are matched by their full value.
+// The key host matches both the engine's id and the values of its host labels.
+//
+// The supported forms of a constraint are:
+//
+//	label             the engine has the label
+//	key==value        the value of key equals value, or matches it as a glob if value contains * ? or [
+//	key!=value        the value of key does not equal or match value
+//	key=~regex        the value of key matches the regular expression
+//	key!~regex        the value of key does not match the regular expression
+//	key in (a, b)     the value of key is one of the values
+//	key not in (a, b) the value of key is none of the values
+//	key>n key>=n key<n key<=n
+//	                  the value of key is a number compared with n
+type Constraint struct {
+	Key      string
+	Operator string
+	Values   []string
+
+	raw    string
+	regex  *regexp.Regexp
+	number float64
+}
+
+const (
+	opLabel    = ""
+	opEqual    = "=="
+	opNotEqual = "!="
+	opMatch    = "=~"
+	opNotMatch = "!~"
+	opIn       = "in"
+	opNotIn    = "not in"
+	opGreater  = ">"
+	opGreaterE = ">="
+	opLess     = "<"
+	opLessE    = "<="
+)
+
+// operators are ordered so that two character operators are found before their prefixes
+var operators = []string{opEqual, opNotEqual, opMatch, opNotMatch, opGreaterE, opLessE, opGreater, opLess}
+
+var (
+	keyPattern = regexp.MustCompile(`^[A-Za-z0-9_.\-/]+$`)
+	inPattern  = regexp.MustCompile(`^(\S+)\s+(not\s+in|in)\s*(.*)$`)
+)
+
+// ParseConstraints parses all of the constraints returning the first error
+func ParseConstraints(raw []string) ([]*Constraint, error) {
+	out := []*Constraint{}
+
+	for _, r := range raw {
+		c, err := ParseConstraint(r)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, c)
+	}
+
+	return out, nil
+}
+
+// ParseConstraint parses a single constraint
+func ParseConstraint(raw string) (*Constraint, error) {
+	s := strings.TrimSpace(raw)
+	if s == "" {
+		return nil, constraintError(raw, "constraint is empty")
+	}
+
+	if m := inPattern.FindStringSubmatch(s); m != nil && keyPattern.MatchString(m[1]) {
+		return parseIn(raw, m[1], strings.Join(strings.Fields(m[2]), " "), m[3])
+	}
+
+	key, op, value, found := splitOperator(s)
+	if !found {
+		if strings.ContainsAny(s, " \t()") {
+			return nil, constraintError(raw, "label must not contain spaces or parentheses")
+		}
+
+		return &Constraint{Key: s, Operator: opLabel, Values: []string{s}, raw: raw}, nil
+	}
+
+	if key == "" {
+		return nil, constraintError(raw, fmt.Sprintf("missing key before %s", op))
+	}
+
+	if !keyPattern.MatchString(key) {
+		return nil, constraintError(raw, fmt.Sprintf("invalid key %q", key))
+	}
+
+	if value == "" {
+		return nil, constraintError(raw, fmt.Sprintf("missing value after %s", op))
+	}
+
+	c := &Constraint{Key: key, Operator: op, Values: []string{value}, raw: raw}
+
+	switch op {
+	case opEqual, opNotEqual:
+		if isGlob(value) {
+			if _, err := path.Match(value, ""); err != nil {
+				return nil, constraintError(raw, fmt.Sprintf("invalid glob %q", value))
+			}
+		}
+	case opMatch, opNotMatch:
+		r, err := regexp.Compile(value)
+		if err != nil {
+			return nil, constraintError(raw, fmt.Sprintf("invalid regular expression: %s", err))
+		}
+		c.regex = r
+	default:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, constraintError(raw, fmt.Sprintf("%s requires a number; received %q", op, value))
+		}
+		c.number = n
+	}
+
+	return c, nil
+}
+
+func parseIn(raw, key, op, list string) (*Constraint, error) {
+	list = strings.TrimSpace(list)
+	if !strings.HasPrefix(list, "(") || !strings.HasSuffix(list, ")") {
+		return nil, constraintError(raw, fmt.Sprintf("%s requires a list of values in parentheses", op))
+	}
+
+	values := []string{}
+	for _, v := range strings.Split(list[1:len(list)-1], ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			return nil, constraintError(raw, fmt.Sprintf("empty value in %s list", op))
+		}
+
+		values = append(values, v)
+	}
+
+	return &Constraint{Key: key, Operator: op, Values: values, raw: raw}, nil
+}
+
+// splitOperator finds the first operator in s returning the trimmed key and value
+// on either side of it
+func splitOperator(s string) (string, string, string, bool) {
+	for i := 0; i < len(s); i++ {
+		for _, op := range operators {
+			if strings.HasPrefix(s[i:], op) {
+				return strings.TrimSpace(s[:i]), op, strings.TrimSpace(s[i+len(op):]), true
+			}
+		}
+	}
+
+	return "", "", "", false
+}
+
+// Match returns true if the engine satisfies the constraint
+func (c *Constraint) Match(e *citadel.Engine) bool {
+	if c.Operator == opLabel {
+		for _, l := range e.Labels {
+			if l == c.Key {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return c.MatchValues(engineValues(e, c.Key))
+}
+
+// MatchValues returns true if the values of the constraint's key satisfy the constraint.
+// Negative operators are satisfied when none of the values match.
+func (c *Constraint) MatchValues(values []string) bool {
+	switch c.Operator {
+	case opNotEqual, opNotMatch, opNotIn:
+		for _, v := range values {
+			if c.matchValue(v) {
+				return false
+			}
+		}
+
+		return true
+	default:
+		for _, v := range values {
+			if c.matchValue(v) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// matchValue returns true if the value satisfies the positive form of the operator
+func (c *Constraint) matchValue(v string) bool {
+	switch c.Operator {
+	case opEqual, opNotEqual:
+		if isGlob(c.Values[0]) {
+			ok, _ := path.Match(c.Values[0], v)
+
+			return ok
+		}
+
+		return v == c.Values[0]
+	case opMatch, opNotMatch:
+		return c.regex.MatchString(v)
+	case opIn, opNotIn:
+		for _, want := range c.Values {
+			if v == want {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return false
+	}
+
+	switch c.Operator {
+	case opGreater:
+		return n > c.number
+	case opGreaterE:
+		return n >= c.number
+	case opLess:
+		return n < c.number
+	case opLessE:
+		return n <= c.number
+	}
+
+	return false
+}
+
+func (c *Constraint) String() string {
+	return c.raw
+}
+
+// engineValues returns the values of the key in the engine's key=value labels.  The
+// values of host also include the engine's id.
+func engineValues(e *citadel.Engine, key string) []string {
+	out := []string{}
+
+	if key == "host" {
+		out = append(out, e.ID)
+	}
+
+	for _, l := range e.Labels {
+		parts := strings.SplitN(l, "=", 2)
+		if len(parts) == 2 && parts[0] == key {
+			out = append(out, parts[1])
+		}
+	}
+
+	return out
+}
+
+func isGlob(v string) bool {
+	return strings.ContainsAny(v, "*?[")
+}
+
+func constraintError(raw, msg string) error {
+	return fmt.Errorf("invalid constraint %q: %s", raw, msg)
+}
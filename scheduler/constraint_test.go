@@ -0,0 +1,183 @@
+package scheduler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/citadel/citadel"
+)
+
+func TestParseConstraint(t *testing.T) {
+	tests := []struct {
+		raw      string
+		key      string
+		operator string
+		values   []string
+	}{
+		{"local", "local", opLabel, []string{"local"}},
+		{"zone==us-east-1", "zone", opEqual, []string{"us-east-1"}},
+		{"zone == us-east-1", "zone", opEqual, []string{"us-east-1"}},
+		{"zone!=us-*", "zone", opNotEqual, []string{"us-*"}},
+		{"zone=~^us-(east|west)", "zone", opMatch, []string{"^us-(east|west)"}},
+		{"zone!~west", "zone", opNotMatch, []string{"west"}},
+		{"zone in (a, b,c)", "zone", opIn, []string{"a", "b", "c"}},
+		{"zone not  in (a)", "zone", opNotIn, []string{"a"}},
+		{"cores>=4", "cores", opGreaterE, []string{"4"}},
+		{"cores<=4", "cores", opLessE, []string{"4"}},
+		{"cores>4", "cores", opGreater, []string{"4"}},
+		{"load<0.5", "load", opLess, []string{"0.5"}},
+		{"version==1.2=beta", "version", opEqual, []string{"1.2=beta"}},
+	}
+
+	for _, test := range tests {
+		c, err := ParseConstraint(test.raw)
+		if err != nil {
+			t.Fatalf("%s: %s", test.raw, err)
+		}
+
+		if c.Key != test.key || c.Operator != test.operator || strings.Join(c.Values, "|") != strings.Join(test.values, "|") {
+			t.Fatalf("%s: expected %s %q %v; received %s %q %v", test.raw, test.key, test.operator, test.values, c.Key, c.Operator, c.Values)
+		}
+	}
+}
+
+func TestParseConstraintErrors(t *testing.T) {
+	tests := []struct {
+		raw string
+		err string
+	}{
+		{"", "constraint is empty"},
+		{"==foo", "missing key before =="},
+		{"zone==", "missing value after =="},
+		{"zo ne==a", "invalid key"},
+		{"zone=~(", "invalid regular expression"},
+		{"zone==[a", "invalid glob"},
+		{"cores>=four", ">= requires a number"},
+		{"zone in a, b", "in requires a list of values in parentheses"},
+		{"zone in (a,,b)", "empty value in in list"},
+		{"zone not in ()", "empty value in not in list"},
+		{"us east", "label must not contain spaces"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseConstraint(test.raw)
+		if err == nil {
+			t.Fatalf("%q: expected error containing %q", test.raw, test.err)
+		}
+
+		if !strings.Contains(err.Error(), test.err) {
+			t.Fatalf("%q: expected error containing %q; received %q", test.raw, test.err, err)
+		}
+	}
+}
+
+func TestConstraintMatch(t *testing.T) {
+	engine := &citadel.Engine{
+		ID:     "node-1",
+		Labels: []string{"local", "zone=us-east-1", "disk=ssd", "cores=8", "load=0.25"},
+	}
+
+	tests := []struct {
+		raw      string
+		expected bool
+	}{
+		{"local", true},
+		{"remote", false},
+		{"zone=us-east-1", true},
+		{"zone==us-east-1", true},
+		{"zone==us-west-1", false},
+		{"zone==us-*", true},
+		{"zone==eu-*", false},
+		{"zone!=us-west-1", true},
+		{"zone!=us-*", false},
+		{"rack!=a", true},
+		{"rack==a", false},
+		{"zone=~^us-(east|west)-[0-9]$", true},
+		{"zone!~east", false},
+		{"disk in (ssd, nvme)", true},
+		{"disk in (hdd)", false},
+		{"disk not in (hdd)", true},
+		{"disk not in (ssd)", false},
+		{"cores>4", true},
+		{"cores>=8", true},
+		{"cores<8", false},
+		{"load<=0.25", true},
+		{"disk>1", false},
+		{"rack>1", false},
+		{"host==node-1", true},
+		{"host in (node-2, node-3)", false},
+	}
+
+	for _, test := range tests {
+		c, err := ParseConstraint(test.raw)
+		if err != nil {
+			t.Fatalf("%s: %s", test.raw, err)
+		}
+
+		if actual := c.Match(engine); actual != test.expected {
+			t.Fatalf("%s: expected %v; received %v", test.raw, test.expected, actual)
+		}
+	}
+}
+
+func TestConstraintMatchHostLabel(t *testing.T) {
+	engine := &citadel.Engine{
+		ID:     "node-1",
+		Labels: []string{"host=web-1"},
+	}
+
+	tests := []struct {
+		raw      string
+		expected bool
+	}{
+		{"host==node-1", true},
+		{"host==web-1", true},
+		{"host in (web-2, node-1)", true},
+		{"host==node-2", false},
+		{"host!=node-1", false},
+		{"host!=web-1", false},
+		{"host!=node-2", true},
+	}
+
+	for _, test := range tests {
+		c, err := ParseConstraint(test.raw)
+		if err != nil {
+			t.Fatalf("%s: %s", test.raw, err)
+		}
+
+		if actual := c.Match(engine); actual != test.expected {
+			t.Fatalf("%s: expected %v; received %v", test.raw, test.expected, actual)
+		}
+	}
+}
+
+func TestHostSchedulerConstraint(t *testing.T) {
+	var (
+		s      = &HostScheduler{}
+		engine = &citadel.Engine{ID: "node-1"}
+	)
+
+	tests := []struct {
+		labels   []string
+		expected bool
+	}{
+		{[]string{}, true},
+		{[]string{"host:node-1"}, true},
+		{[]string{"host:node-2", "host:node-1"}, true},
+		{[]string{"host:node-2"}, false},
+		{[]string{"host:node-1", "local"}, false},
+		{[]string{"host:node-1,node-2"}, false},
+		{[]string{"host:node-1)"}, false},
+	}
+
+	for _, test := range tests {
+		d, err := s.Schedule(&citadel.Image{Labels: test.labels}, engine)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual := d.Accepted; actual != test.expected {
+			t.Fatalf("%v: expected %v; received %v", test.labels, test.expected, actual)
+		}
+	}
+}
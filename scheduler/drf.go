@@ -0,0 +1,90 @@
+package scheduler
+
+import (
+	"math"
+
+	"github.com/citadel/citadel"
+)
+
+// DRFAllocator orders the images waiting to be placed by Dominant Resource Fairness.  The
+// dominant share of a tenant is the larger of its share of the cpus and its share of the
+// memory of the cluster.  The next image is always taken from the tenant with the lowest
+// dominant share, counting the images ordered before it as placed, so that tenants with
+// different resource needs converge to equal dominant shares.
+type DRFAllocator struct {
+}
+
+// Order returns the indexes of the images in the order they should be placed.  Images of
+// the same tenant keep their order.
+func (d *DRFAllocator) Order(info *citadel.ClusterInfo, images []*citadel.Image) []int {
+	var (
+		out     = []int{}
+		usage   = make(map[string]*citadel.TenantUsage)
+		pending = make(map[string][]int)
+		tenants = []string{}
+	)
+
+	for tenant, u := range info.Tenants {
+		copied := *u
+		usage[tenant] = &copied
+	}
+
+	for i, image := range images {
+		if pending[image.Tenant] == nil {
+			tenants = append(tenants, image.Tenant)
+		}
+
+		pending[image.Tenant] = append(pending[image.Tenant], i)
+	}
+
+	for len(out) < len(images) {
+		next, lowest := "", math.Inf(1)
+
+		// tenants are visited in the order of their first image so that ties are FIFO
+		for _, tenant := range tenants {
+			if len(pending[tenant]) == 0 {
+				continue
+			}
+
+			if share := DominantShare(info, usage[tenant]); share < lowest {
+				next, lowest = tenant, share
+			}
+		}
+
+		i := pending[next][0]
+		pending[next] = pending[next][1:]
+		out = append(out, i)
+
+		u := usage[next]
+		if u == nil {
+			u = &citadel.TenantUsage{}
+			usage[next] = u
+		}
+
+		u.Cpus += images[i].Cpus
+		u.Memory += images[i].Memory
+		u.Containers++
+	}
+
+	return out
+}
+
+// DominantShare returns the larger of the tenant's share of the cpus and of the memory
+// of the cluster
+func DominantShare(info *citadel.ClusterInfo, u *citadel.TenantUsage) float64 {
+	if u == nil {
+		return 0
+	}
+
+	share := 0.0
+
+	if info.Cpus > 0 {
+		share = math.Max(share, u.Cpus/info.Cpus)
+	}
+
+	if info.Memory > 0 {
+		share = math.Max(share, u.Memory/info.Memory)
+	}
+
+	return share
+}
@@ -0,0 +1,103 @@
+package scheduler
+
+import (
+	"math"
+	"testing"
+
+	"github.com/citadel/citadel"
+)
+
+// simulate places the images in the order of the allocator until the cluster is full,
+// dropping the pending images of a tenant once one of them no longer fits
+func simulate(info *citadel.ClusterInfo, pending []*citadel.Image) {
+	d := &DRFAllocator{}
+
+	for len(pending) > 0 {
+		image := pending[d.Order(info, pending)[0]]
+
+		if info.ReservedCpus+image.Cpus > info.Cpus || info.ReservedMemory+image.Memory > info.Memory {
+			remaining := []*citadel.Image{}
+			for _, i := range pending {
+				if i.Tenant != image.Tenant {
+					remaining = append(remaining, i)
+				}
+			}
+			pending = remaining
+
+			continue
+		}
+
+		u := info.Tenants[image.Tenant]
+		if u == nil {
+			u = &citadel.TenantUsage{}
+			info.Tenants[image.Tenant] = u
+		}
+
+		u.Cpus += image.Cpus
+		u.Memory += image.Memory
+		u.Containers++
+		info.ReservedCpus += image.Cpus
+		info.ReservedMemory += image.Memory
+
+		for i, p := range pending {
+			if p == image {
+				pending = append(pending[:i], pending[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func requests(tenant string, cpus, memory float64, count int) []*citadel.Image {
+	out := []*citadel.Image{}
+	for i := 0; i < count; i++ {
+		out = append(out, &citadel.Image{Name: tenant, Tenant: tenant, Cpus: cpus, Memory: memory})
+	}
+
+	return out
+}
+
+func TestDRFSimulation(t *testing.T) {
+	// the example from the DRF paper: tenant a runs memory heavy containers and tenant b
+	// cpu heavy containers on a cluster of 9 cpus and 18 GB and both ask for more than
+	// the cluster can hold, with a queuing all of its requests first
+	info := &citadel.ClusterInfo{Cpus: 9, Memory: 18432, Tenants: make(map[string]*citadel.TenantUsage)}
+
+	simulate(info, append(requests("a", 1, 4096, 10), requests("b", 3, 1024, 10)...))
+
+	a, b := info.Tenants["a"], info.Tenants["b"]
+	if a.Containers != 3 || b.Containers != 2 {
+		t.Fatalf("expected 3 containers for a and 2 for b; received %d and %d", a.Containers, b.Containers)
+	}
+
+	if sa, sb := DominantShare(info, a), DominantShare(info, b); math.Abs(sa-sb) > 0.01 {
+		t.Fatalf("expected equal dominant shares; received %f and %f", sa, sb)
+	}
+}
+
+func TestDRFConvergence(t *testing.T) {
+	// tenant b already uses half of the cpus so tenant a is served until it catches up
+	info := &citadel.ClusterInfo{
+		Cpus:         16,
+		Memory:       16384,
+		ReservedCpus: 8,
+		Tenants: map[string]*citadel.TenantUsage{
+			"b": {Cpus: 8, Containers: 8},
+		},
+	}
+
+	pending := append(requests("b", 1, 0, 8), requests("a", 1, 0, 8)...)
+
+	order := (&DRFAllocator{}).Order(info, pending)
+	for _, i := range order[:8] {
+		if pending[i].Tenant != "a" {
+			t.Fatalf("expected the first 8 images to be of tenant a; received %v", order)
+		}
+	}
+
+	simulate(info, pending)
+
+	if sa, sb := DominantShare(info, info.Tenants["a"]), DominantShare(info, info.Tenants["b"]); sa != 0.5 || sb != 0.5 {
+		t.Fatalf("expected both tenants to converge to half of the cluster; received %f and %f", sa, sb)
+	}
+}
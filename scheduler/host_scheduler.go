@@ -6,29 +6,47 @@ import (
 	"github.com/citadel/citadel"
 )
 
+// HostScheduler only returns the engines named by host:<id> labels.  It is the
+// constraint host in (<id>, ...) built from those labels.
 type HostScheduler struct {
 }
 
-func (h *HostScheduler) Schedule(c *citadel.Image, e *citadel.Engine) (bool, error) {
+func (h *HostScheduler) Schedule(c *citadel.Image, e *citadel.Engine) (*citadel.Decision, error) {
 	if len(c.Labels) == 0 {
-		return true, nil
+		return citadel.Accept("host"), nil
 	}
 
-	return h.validHost(e, c.Labels), nil
+	con, label := h.constraint(c.Labels)
+	if label != "" {
+		return citadel.Reject("host", "label %q does not name a host", label), nil
+	}
+
+	if !con.Match(e) {
+		return citadel.Reject("host", "engine does not match %q", con), nil
+	}
+
+	return citadel.Accept("host"), nil
 }
 
-func (h *HostScheduler) validHost(e *citadel.Engine, labels []string) bool {
+// constraint returns the constraint for the host labels or the first label that
+// does not name a host.  The constraint is built from the values so that host ids
+// are not parsed as constraint syntax.
+func (h *HostScheduler) constraint(labels []string) (*Constraint, string) {
+	hosts := []string{}
+
 	for _, label := range labels {
 		parts := strings.Split(label, "host:")
 		if len(parts) != 2 {
-			return false
+			return nil, label
 		}
 
-		host := parts[1]
-		if e.ID == host {
-			return true
-		}
+		hosts = append(hosts, parts[1])
 	}
 
-	return false
+	return &Constraint{
+		Key:      "host",
+		Operator: opIn,
+		Values:   hosts,
+		raw:      "host in (" + strings.Join(hosts, ", ") + ")",
+	}, ""
 }
@@ -12,7 +12,7 @@ import (
 type ImageScheduler struct {
 }
 
-func (i *ImageScheduler) Schedule(c *citadel.Image, e *citadel.Engine) (bool, error) {
+func (i *ImageScheduler) Schedule(c *citadel.Image, e *citadel.Engine) (*citadel.Decision, error) {
 	fullImage := c.Name
 
 	if !strings.Contains(fullImage, ":") {
@@ -21,14 +21,14 @@ func (i *ImageScheduler) Schedule(c *citadel.Image, e *citadel.Engine) (bool, er
 
 	images, err := e.ListImages()
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
 	if i.containsImage(fullImage, images) {
-		return true, nil
+		return citadel.Accept("image"), nil
 	}
 
-	return false, nil
+	return citadel.Reject("image", "engine does not have %s", fullImage), nil
 }
 
 func (i *ImageScheduler) containsImage(requested string, images []string) bool {
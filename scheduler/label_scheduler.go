@@ -2,33 +2,22 @@ package scheduler
 
 import "github.com/citadel/citadel"
 
+// LabelScheduler only returns engines that satisfy all of the image's labels
+// as constraints
 type LabelScheduler struct {
 }
 
-func (l *LabelScheduler) Schedule(c *citadel.Image, e *citadel.Engine) (bool, error) {
-	if len(c.Labels) == 0 || l.contains(e, c.Labels) {
-		return true, nil
+func (l *LabelScheduler) Schedule(c *citadel.Image, e *citadel.Engine) (*citadel.Decision, error) {
+	constraints, err := ParseConstraints(c.Labels)
+	if err != nil {
+		return nil, err
 	}
 
-	return false, nil
-}
-
-func (l *LabelScheduler) contains(r *citadel.Engine, constraints []string) bool {
-	for _, c := range constraints {
-		if !l.resourceContains(r, c) {
-			return false
-		}
-	}
-
-	return true
-}
-
-func (l *LabelScheduler) resourceContains(r *citadel.Engine, c string) bool {
-	for _, l := range r.Labels {
-		if l == c {
-			return true
+	for _, con := range constraints {
+		if !con.Match(e) {
+			return citadel.Reject("label", "engine does not match %q", con), nil
 		}
 	}
 
-	return false
+	return citadel.Accept("label"), nil
 }
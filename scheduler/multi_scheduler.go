@@ -2,27 +2,69 @@ package scheduler
 
 import "github.com/citadel/citadel"
 
+// MultiScheduler only returns engines accepted by all of its schedulers and scores
+// engines with the weighted average of its scorers
 type MultiScheduler struct {
 	schedulers []citadel.Scheduler
+	scorers    []*weightedScorer
 }
 
-func NewMultiScheduler(s ...citadel.Scheduler) citadel.Scheduler {
+type weightedScorer struct {
+	scorer citadel.Scorer
+	weight float64
+}
+
+func NewMultiScheduler(s ...citadel.Scheduler) *MultiScheduler {
 	return &MultiScheduler{
 		schedulers: s,
 	}
 }
 
-func (m *MultiScheduler) Schedule(c *citadel.Image, e *citadel.Engine) (bool, error) {
+// AddScorer adds a scorer whose score is weighted relative to the other scorers
+func (m *MultiScheduler) AddScorer(s citadel.Scorer, weight float64) {
+	m.scorers = append(m.scorers, &weightedScorer{
+		scorer: s,
+		weight: weight,
+	})
+}
+
+// Schedulers returns the schedulers that all have to accept an engine
+func (m *MultiScheduler) Schedulers() []citadel.Scheduler {
+	return m.schedulers
+}
+
+// Schedule returns the decision of the first scheduler that rejects the engine
+func (m *MultiScheduler) Schedule(c *citadel.Image, e *citadel.Engine) (*citadel.Decision, error) {
 	for _, s := range m.schedulers {
-		canrun, err := s.Schedule(c, e)
+		d, err := s.Schedule(c, e)
 		if err != nil {
-			return false, err
+			return nil, err
+		}
+
+		if !d.Accepted {
+			return d, nil
 		}
+	}
+
+	return citadel.Accept("multi"), nil
+}
+
+func (m *MultiScheduler) Score(c *citadel.Image, e *citadel.Engine) (float64, error) {
+	var score, total float64
 
-		if !canrun {
-			return false, nil
+	for _, s := range m.scorers {
+		v, err := s.scorer.Score(c, e)
+		if err != nil {
+			return 0, err
 		}
+
+		score += v * s.weight
+		total += s.weight
+	}
+
+	if total == 0 {
+		return 0, nil
 	}
 
-	return true, nil
+	return score / total, nil
 }
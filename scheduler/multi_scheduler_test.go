@@ -0,0 +1,34 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/citadel/citadel"
+)
+
+func TestMultiSchedulerDecision(t *testing.T) {
+	var (
+		m      = NewMultiScheduler(&LabelScheduler{}, &VolumeScheduler{})
+		engine = &citadel.Engine{ID: "local", Labels: []string{"zone=east"}}
+		image  = &citadel.Image{Labels: []string{"zone==east"}, Volumes: []string{"data:/var/lib/data"}}
+	)
+
+	d, err := m.Schedule(image, engine)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Accepted || d.Scheduler != "volume" || d.Reason != "engine does not host volume data" {
+		t.Fatalf("expected the volume scheduler to reject the engine; received %s", d)
+	}
+
+	engine.Volumes = []string{"data"}
+
+	if d, err = m.Schedule(image, engine); err != nil {
+		t.Fatal(err)
+	}
+
+	if !d.Accepted {
+		t.Fatalf("expected the engine to be accepted; received %s", d)
+	}
+}
@@ -0,0 +1,48 @@
+package scheduler
+
+import "github.com/citadel/citadel"
+
+// PortScheduler only returns engines where the host ports bound by the image are not
+// bound by the containers running on the engine.  Ports without a host port are allocated
+// from the engine's port range, so the engine must have enough free ports in its range.
+// The cluster checks the ports again against its reservations when the image is placed
+// so that containers that are still being started are accounted for.
+type PortScheduler struct {
+}
+
+func (p *PortScheduler) Schedule(c *citadel.Image, e *citadel.Engine) (*citadel.Decision, error) {
+	if len(c.BindPorts) == 0 {
+		return citadel.Accept("port"), nil
+	}
+
+	containers, err := e.ListContainers(false)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok, reason := p.available(c.BindPorts, boundPorts(containers), e.PortRange); !ok {
+		return citadel.Reject("port", reason), nil
+	}
+
+	return citadel.Accept("port"), nil
+}
+
+// boundPorts returns the host ports bound by the containers
+func boundPorts(containers []*citadel.Container) []*citadel.Port {
+	out := []*citadel.Port{}
+
+	for _, c := range containers {
+		out = append(out, c.Ports...)
+	}
+
+	return out
+}
+
+// available returns true if the ports can be bound or false and the reason they can not
+func (p *PortScheduler) available(binds, used []*citadel.Port, portRange *citadel.PortRange) (bool, string) {
+	if _, err := citadel.AllocatePorts(binds, used, portRange); err != nil {
+		return false, err.Error()
+	}
+
+	return true, ""
+}
@@ -0,0 +1,39 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/citadel/citadel"
+)
+
+func TestPortSchedulerAvailable(t *testing.T) {
+	var (
+		s    = &PortScheduler{}
+		used = boundPorts([]*citadel.Container{
+			{Ports: []*citadel.Port{{Proto: "tcp", Port: 80, ContainerPort: 80}}},
+			{Ports: []*citadel.Port{{Proto: "udp", HostIp: "10.0.0.1", Port: 53, ContainerPort: 53}}},
+		})
+	)
+
+	tests := []struct {
+		name      string
+		binds     []*citadel.Port
+		portRange *citadel.PortRange
+		expected  bool
+	}{
+		{"same port", []*citadel.Port{{Proto: "tcp", Port: 80}}, nil, false},
+		{"same port on an ip", []*citadel.Port{{Proto: "tcp", HostIp: "10.0.0.2", Port: 80}}, nil, false},
+		{"other proto", []*citadel.Port{{Proto: "udp", Port: 80}}, nil, true},
+		{"other ip", []*citadel.Port{{Proto: "udp", HostIp: "10.0.0.2", Port: 53}}, nil, true},
+		{"dynamic without range", []*citadel.Port{{Proto: "tcp"}}, nil, true},
+		{"dynamic range taken", []*citadel.Port{{Proto: "tcp"}}, &citadel.PortRange{Start: 80, End: 80}, false},
+		{"dynamic range free", []*citadel.Port{{Proto: "tcp"}, {Proto: "tcp"}}, &citadel.PortRange{Start: 80, End: 82}, true},
+		{"dynamic range exhausted", []*citadel.Port{{Proto: "tcp"}, {Proto: "tcp"}}, &citadel.PortRange{Start: 80, End: 81}, false},
+	}
+
+	for _, test := range tests {
+		if actual, _ := s.available(test.binds, used, test.portRange); actual != test.expected {
+			t.Fatalf("%s: expected %v; received %v", test.name, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package scheduler
+
+import (
+	"sort"
+
+	"github.com/citadel/citadel"
+)
+
+// maxPreemptionCandidates is the number of containers of an engine, lowest priority and
+// then largest first, searched for the minimal set to preempt so that the search stays cheap
+const maxPreemptionCandidates = 12
+
+// Preempt returns the engine where the container fits by stopping the fewest running
+// containers of a lower priority, along with the reservations of those containers.  Ties
+// are broken by the lowest total priority of the containers stopped.  ErrNoResources is
+// returned if preemption is disabled or no engine can make enough room.
+func (r *ResourceManager) Preempt(c *citadel.Container, engines []*citadel.EngineSnapshot) (*citadel.EngineSnapshot, []*citadel.Reservation, error) {
+	if !r.Preemption {
+		return nil, nil, citadel.ErrNoResources
+	}
+
+	var (
+		best    *citadel.EngineSnapshot
+		victims []*citadel.Reservation
+	)
+
+	for _, e := range engines {
+		v, ok := r.victims(c, e)
+		if !ok {
+			continue
+		}
+
+		if best == nil || fewerVictims(v, victims) {
+			best, victims = e, v
+		}
+	}
+
+	if best == nil {
+		return nil, nil, citadel.ErrNoResources
+	}
+
+	return best, victims, nil
+}
+
+// victims returns the set of containers of a lower priority to stop on the engine for the
+// container to fit.  The set is minimal among the first maxPreemptionCandidates containers
+// only, so minimality is best effort.  When stopping all of those is not enough the
+// remaining containers are added in order until the container fits.
+func (r *ResourceManager) victims(c *citadel.Container, e *citadel.EngineSnapshot) ([]*citadel.Reservation, bool) {
+	candidates := []*citadel.Reservation{}
+	for _, res := range e.Reservations {
+		if priority(res) < c.Image.Priority {
+			candidates = append(candidates, res)
+		}
+	}
+
+	sort.Sort(byPreemption(candidates))
+
+	searched := candidates
+	if len(searched) > maxPreemptionCandidates {
+		searched = searched[:maxPreemptionCandidates]
+	}
+
+	for k := 1; k <= len(searched); k++ {
+		var best []*citadel.Reservation
+
+		combinations(len(searched), k, func(indexes []int) {
+			set := []*citadel.Reservation{}
+			for _, i := range indexes {
+				set = append(set, searched[i])
+			}
+
+			if (best == nil || fewerVictims(set, best)) && r.fits(c, e, set) {
+				best = set
+			}
+		})
+
+		if best != nil {
+			return best, true
+		}
+	}
+
+	for n := len(searched) + 1; n <= len(candidates); n++ {
+		if r.fits(c, e, candidates[:n]) {
+			return candidates[:n], true
+		}
+	}
+
+	return nil, false
+}
+
+// fits returns true if the container can be placed on the engine once the victims are stopped
+func (r *ResourceManager) fits(c *citadel.Container, e *citadel.EngineSnapshot, victims []*citadel.Reservation) bool {
+	s := *e
+
+	for _, v := range victims {
+		s.ReservedCpus -= v.Cpus
+		s.ReservedMemory -= v.Memory
+		s.ReservedDisk -= v.Disk
+
+		// the live usage of the victims is not known so their reservations are used instead
+		if s.CurrentCpu -= v.Cpus; s.CurrentCpu < 0 {
+			s.CurrentCpu = 0
+		}
+
+		if s.CurrentMemory -= v.Memory; s.CurrentMemory < 0 {
+			s.CurrentMemory = 0
+		}
+	}
+
+	_, err := r.PlaceContainer(&citadel.Container{Image: c.Image}, []*citadel.EngineSnapshot{&s})
+
+	return err == nil
+}
+
+// fewerVictims returns true if stopping a is preferred to stopping b
+func fewerVictims(a, b []*citadel.Reservation) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+
+	return totalPriority(a) < totalPriority(b)
+}
+
+func totalPriority(reservations []*citadel.Reservation) int {
+	total := 0
+	for _, r := range reservations {
+		total += priority(r)
+	}
+
+	return total
+}
+
+func priority(r *citadel.Reservation) int {
+	if r.Image == nil {
+		return 0
+	}
+
+	return r.Image.Priority
+}
+
+// combinations calls fn with every set of k indexes out of n
+func combinations(n, k int, fn func([]int)) {
+	indexes := make([]int, k)
+
+	var walk func(start, depth int)
+	walk = func(start, depth int) {
+		if depth == k {
+			fn(indexes)
+
+			return
+		}
+
+		for i := start; i <= n-(k-depth); i++ {
+			indexes[depth] = i
+			walk(i+1, depth+1)
+		}
+	}
+
+	walk(0, 0)
+}
+
+// byPreemption orders reservations from the lowest priority and, within a priority, from
+// the largest so that the containers most worth stopping are considered first
+type byPreemption []*citadel.Reservation
+
+func (b byPreemption) Len() int      { return len(b) }
+func (b byPreemption) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byPreemption) Less(i, j int) bool {
+	if pi, pj := priority(b[i]), priority(b[j]); pi != pj {
+		return pi < pj
+	}
+
+	return b[i].Cpus+b[i].Memory/1024 > b[j].Cpus+b[j].Memory/1024
+}
@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/citadel/citadel"
+)
+
+func reservation(id string, priority int, cpus float64) *citadel.Reservation {
+	return &citadel.Reservation{
+		ContainerID: id,
+		Image:       &citadel.Image{Priority: priority},
+		Cpus:        cpus,
+		Memory:      cpus * 256,
+	}
+}
+
+func TestPreempt(t *testing.T) {
+	var (
+		container = &citadel.Container{Image: &citadel.Image{Cpus: 2, Memory: 512, Priority: 10}}
+
+		// one engine needs two containers stopped, the other a single larger one
+		two = &citadel.EngineSnapshot{ID: "two", Cpus: 4, Memory: 1024, ReservedCpus: 4, ReservedMemory: 1024, Reservations: []*citadel.Reservation{
+			reservation("a", 0, 1), reservation("b", 0, 1), reservation("c", 20, 2),
+		}}
+		one = &citadel.EngineSnapshot{ID: "one", Cpus: 4, Memory: 1024, ReservedCpus: 4, ReservedMemory: 1024, Reservations: []*citadel.Reservation{
+			reservation("d", 0, 1), reservation("e", 5, 2), reservation("f", 0, 1),
+		}}
+		r = NewResourceManager()
+	)
+
+	if _, _, err := r.Preempt(container, []*citadel.EngineSnapshot{two, one}); err != citadel.ErrNoResources {
+		t.Fatalf("expected no preemption when it is disabled; received %v", err)
+	}
+
+	r.Preemption = true
+
+	s, victims, err := r.Preempt(container, []*citadel.EngineSnapshot{two, one})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.ID != "one" || len(victims) != 1 || victims[0].ContainerID != "e" {
+		t.Fatalf("expected container e to be stopped on engine one; received %s %v", s.ID, victims)
+	}
+
+	// containers of the same or a higher priority are never stopped
+	container.Image.Priority = 0
+
+	if _, _, err := r.Preempt(container, []*citadel.EngineSnapshot{two, one}); err != citadel.ErrNoResources {
+		t.Fatalf("expected no victims of a lower priority; received %v", err)
+	}
+}
+
+func TestPreemptBeyondCandidates(t *testing.T) {
+	var (
+		container = &citadel.Container{Image: &citadel.Image{Cpus: 14, Memory: 3584, Priority: 10}}
+		engine    = &citadel.EngineSnapshot{ID: "full", Cpus: 16, Memory: 4096, ReservedCpus: 16, ReservedMemory: 4096}
+		r         = NewResourceManager()
+	)
+	r.Preemption = true
+
+	for _, id := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m", "n", "o", "p"} {
+		engine.Reservations = append(engine.Reservations, reservation(id, 0, 1))
+	}
+
+	_, victims, err := r.Preempt(container, []*citadel.EngineSnapshot{engine})
+	if err != nil {
+		t.Fatalf("expected containers beyond the searched candidates to be stopped; received %v", err)
+	}
+
+	if len(victims) != 14 {
+		t.Fatalf("expected 14 containers to be stopped; received %d", len(victims))
+	}
+}
@@ -0,0 +1,36 @@
+package scheduler
+
+import "github.com/citadel/citadel"
+
+// PreferenceScorer scores engines by the weighted fraction of the image's
+// preferences that they match.  A preference without a weight has a weight of 1.
+type PreferenceScorer struct {
+}
+
+func (p *PreferenceScorer) Score(c *citadel.Image, e *citadel.Engine) (float64, error) {
+	var matched, total float64
+
+	for _, pref := range c.Preferences {
+		con, err := ParseConstraint(pref.Constraint)
+		if err != nil {
+			return 0, err
+		}
+
+		weight := pref.Weight
+		if weight == 0 {
+			weight = 1
+		}
+
+		total += weight
+
+		if con.Match(e) {
+			matched += weight
+		}
+	}
+
+	if total == 0 {
+		return 0, nil
+	}
+
+	return matched / total, nil
+}
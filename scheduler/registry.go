@@ -0,0 +1,162 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/citadel/citadel"
+)
+
+// Options are the raw options given to a scheduler in a pipeline
+type Options json.RawMessage
+
+// Decode decodes the options into v, which is left unchanged when there are no options
+func (o Options) Decode(v interface{}) error {
+	if o.empty() {
+		return nil
+	}
+
+	return json.Unmarshal([]byte(o), v)
+}
+
+func (o Options) empty() bool {
+	trimmed := bytes.TrimSpace([]byte(o))
+
+	return len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null"))
+}
+
+// Factory returns a new scheduler configured with the options, which the factory decodes
+// into its own options type
+type Factory func(Options) (citadel.Scheduler, error)
+
+var (
+	factoriesMux sync.Mutex
+	factories    = make(map[string]Factory)
+)
+
+func init() {
+	Register("label", noOptions("label", func() citadel.Scheduler { return &LabelScheduler{} }))
+	Register("host", noOptions("host", func() citadel.Scheduler { return &HostScheduler{} }))
+	Register("unique", noOptions("unique", func() citadel.Scheduler { return &UniqueScheduler{} }))
+	Register("image", noOptions("image", func() citadel.Scheduler { return &ImageScheduler{} }))
+	Register("port", noOptions("port", func() citadel.Scheduler { return &PortScheduler{} }))
+	Register("volume", noOptions("volume", func() citadel.Scheduler { return &VolumeScheduler{} }))
+	Register("affinity", noOptions("affinity", func() citadel.Scheduler { return &AffinityScheduler{} }))
+}
+
+// Register makes the scheduler created by the factory available to pipelines under the
+// name.  It panics if the name is already registered.
+func Register(name string, f Factory) {
+	factoriesMux.Lock()
+	defer factoriesMux.Unlock()
+
+	if f == nil {
+		panic("scheduler: register of a nil factory for " + name)
+	}
+
+	if _, exists := factories[name]; exists {
+		panic("scheduler: register called twice for " + name)
+	}
+
+	factories[name] = f
+}
+
+// Registered returns the sorted names of the registered schedulers
+func Registered() []string {
+	factoriesMux.Lock()
+	defer factoriesMux.Unlock()
+
+	out := []string{}
+	for name := range factories {
+		out = append(out, name)
+	}
+
+	sort.Strings(out)
+
+	return out
+}
+
+// NewScheduler returns a new scheduler of the registered name configured with the options
+func NewScheduler(name string, options Options) (citadel.Scheduler, error) {
+	factoriesMux.Lock()
+	f := factories[name]
+	factoriesMux.Unlock()
+
+	if f == nil {
+		return nil, fmt.Errorf("unknown scheduler %s", name)
+	}
+
+	return f(options)
+}
+
+// Stage is a scheduler of a pipeline.  In JSON a stage is either the name of the
+// scheduler or an object with the name and the options of the scheduler.
+type Stage struct {
+	Name    string  `json:"name,omitempty"`
+	Options Options `json:"options,omitempty"`
+}
+
+func (s *Stage) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		s.Name = name
+
+		return nil
+	}
+
+	var stage struct {
+		Name    string          `json:"name"`
+		Options json.RawMessage `json:"options"`
+	}
+
+	if err := json.Unmarshal(data, &stage); err != nil {
+		return err
+	}
+
+	s.Name = stage.Name
+	s.Options = Options(stage.Options)
+
+	return nil
+}
+
+func (s *Stage) MarshalJSON() ([]byte, error) {
+	if s.Options.empty() {
+		return json.Marshal(s.Name)
+	}
+
+	return json.Marshal(struct {
+		Name    string          `json:"name"`
+		Options json.RawMessage `json:"options"`
+	}{s.Name, json.RawMessage(s.Options)})
+}
+
+// NewPipeline returns a MultiScheduler that only accepts engines accepted by the
+// schedulers of every stage, asked in the order of the stages
+func NewPipeline(stages []*Stage) (*MultiScheduler, error) {
+	schedulers := []citadel.Scheduler{}
+
+	for _, stage := range stages {
+		s, err := NewScheduler(stage.Name, stage.Options)
+		if err != nil {
+			return nil, err
+		}
+
+		schedulers = append(schedulers, s)
+	}
+
+	return NewMultiScheduler(schedulers...), nil
+}
+
+// noOptions returns a factory for a scheduler that can not be configured
+func noOptions(name string, f func() citadel.Scheduler) Factory {
+	return func(o Options) (citadel.Scheduler, error) {
+		if !o.empty() {
+			return nil, fmt.Errorf("scheduler %s does not take options", name)
+		}
+
+		return f(), nil
+	}
+}
@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/citadel/citadel"
+)
+
+// zoneScheduler only accepts engines labeled with its zone
+type zoneScheduler struct {
+	Zone string `json:"zone"`
+}
+
+func (z *zoneScheduler) Schedule(c *citadel.Image, e *citadel.Engine) (*citadel.Decision, error) {
+	for _, l := range e.Labels {
+		if l == "zone="+z.Zone {
+			return citadel.Accept("zone"), nil
+		}
+	}
+
+	return citadel.Reject("zone", "engine is not in zone %s", z.Zone), nil
+}
+
+func init() {
+	Register("zone", func(o Options) (citadel.Scheduler, error) {
+		z := &zoneScheduler{Zone: "default"}
+		if err := o.Decode(z); err != nil {
+			return nil, err
+		}
+
+		return z, nil
+	})
+}
+
+func TestPipelineFromConfig(t *testing.T) {
+	var stages []*Stage
+	if err := json.Unmarshal([]byte(`["label", {"name": "zone", "options": {"zone": "east"}}, "port"]`), &stages); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewPipeline(stages)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schedulers := p.Schedulers()
+	if len(schedulers) != 3 {
+		t.Fatalf("expected 3 schedulers; received %d", len(schedulers))
+	}
+
+	if _, ok := schedulers[0].(*LabelScheduler); !ok {
+		t.Fatalf("expected the label scheduler first; received %T", schedulers[0])
+	}
+
+	if z, ok := schedulers[1].(*zoneScheduler); !ok || z.Zone != "east" {
+		t.Fatalf("expected the zone scheduler with its zone decoded; received %#v", schedulers[1])
+	}
+
+	d, err := p.Schedule(&citadel.Image{}, &citadel.Engine{ID: "local", Labels: []string{"zone=west"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Accepted || d.Scheduler != "zone" {
+		t.Fatalf("expected the zone scheduler to reject the engine; received %s", d)
+	}
+}
+
+func TestNewSchedulerErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+	}{
+		{"missing", ""},
+		{"label", `{"key": "zone"}`},
+		{"zone", `{"zone": 1}`},
+	}
+
+	for _, test := range tests {
+		if _, err := NewScheduler(test.name, Options(test.options)); err == nil {
+			t.Fatalf("expected an error for %s with options %q; received nil", test.name, test.options)
+		}
+	}
+
+	s, err := NewScheduler("zone", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if z := s.(*zoneScheduler); z.Zone != "default" {
+		t.Fatalf("expected the default zone without options; received %s", z.Zone)
+	}
+}
+
+func TestStageJSON(t *testing.T) {
+	for _, raw := range []string{`"label"`, `{"name":"zone","options":{"zone":"east"}}`} {
+		var s *Stage
+		if err := json.Unmarshal([]byte(raw), &s); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := json.Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != raw {
+			t.Fatalf("expected %s; received %s", raw, data)
+		}
+	}
+}
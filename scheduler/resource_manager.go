@@ -1,45 +1,234 @@
 package scheduler
 
 import (
-	"fmt"
-
 	"github.com/citadel/citadel"
 )
 
+// DefaultPreferenceWeight is the share of the preference of an engine in the
+// final score of an engine
+const DefaultPreferenceWeight = 0.5
+
 // ResourceManager is responsible for managing the engines of the cluster
 type ResourceManager struct {
+	// Usage places containers using the live resource usage of the engines and the
+	// reservations of the containers that are not started yet instead of all reservations
+	Usage bool
+
+	// Headroom is the factor applied to the live usage of an engine when Usage is
+	// enabled so that load spikes do not exhaust the engine.  Defaults to 1.
+	Headroom float64
+
+	// PreferenceWeight is the share, between 0 and 1, of the engine's preference in
+	// the score used to choose an engine, the rest being the strategy's score
+	PreferenceWeight float64
+
+	// Estimator when set replaces the cpus and memory requested by an image with the
+	// resources the image is estimated to use when placing and reserving the image.  The
+	// container still runs with the resources it requests.
+	Estimator Estimator
+
+	// Strategy is the placement strategy used for images that do not request one
+	// and whose type has no strategy.  Defaults to binpack.
+	Strategy Strategy
+
+	// Strategies are the placement strategies for each image type
+	Strategies map[string]Strategy
+
+	// Preemption allows a container that does not fit on any engine to stop running
+	// containers of a lower priority
+	Preemption bool
+}
+
+// Estimator returns the cpus and memory that an image is expected to use, or false
+// if there is no estimate for the image
+type Estimator interface {
+	Estimate(*citadel.Image) (float64, float64, bool)
 }
 
 func NewResourceManager() *ResourceManager {
-	return &ResourceManager{}
+	return &ResourceManager{
+		PreferenceWeight: DefaultPreferenceWeight,
+		Strategies:       make(map[string]Strategy),
+	}
+}
+
+// NewUsageResourceManager returns a resource manager that places containers based on
+// the live usage of the engines scaled by headroom and the reservations of the containers
+// that are not started yet
+func NewUsageResourceManager(headroom float64) *ResourceManager {
+	r := NewResourceManager()
+	r.Usage = true
+	r.Headroom = headroom
+
+	return r
 }
 
 // PlaceImage uses the provided engines to make a decision on which resource the container
 // should run based on best utilization of the engines.
 func (r *ResourceManager) PlaceContainer(c *citadel.Container, engines []*citadel.EngineSnapshot) (*citadel.EngineSnapshot, error) {
-	scores := []*score{}
+	c = r.sized(c)
 
-	for _, e := range engines {
-		if e.Memory < c.Image.Memory || e.Cpus < c.Image.Cpus {
-			continue
-		}
+	strategy, err := r.strategy(c.Image)
+	if err != nil {
+		return nil, err
+	}
 
-		var (
-			cpuScore    = ((e.ReservedCpus + c.Image.Cpus) / e.Cpus) * 100.0
-			memoryScore = ((e.ReservedMemory + c.Image.Memory) / e.Memory) * 100.0
-			total       = ((cpuScore + memoryScore) / 200.0) * 100.0
-		)
+	candidates := []*Candidate{}
 
-		if total <= 100.0 {
-			scores = append(scores, &score{r: e, score: total})
+	for _, e := range engines {
+		if s := r.score(c, e); s.Fits {
+			candidates = append(candidates, &Candidate{Engine: e, Fill: s.Total})
 		}
 	}
 
-	if len(scores) == 0 {
-		return nil, fmt.Errorf("no resources avaliable to schedule container")
+	if len(candidates) == 0 {
+		return nil, citadel.ErrNoResources
+	}
+
+	strategy.Rank(c, candidates)
+
+	scores := []*score{}
+	for _, cand := range candidates {
+		scores = append(scores, &score{r: cand.Engine, score: r.blend(cand.Score, cand.Engine)})
 	}
 
 	sortScores(scores)
 
 	return scores[0].r, nil
 }
+
+// Scores returns how full each engine would be with the container placed on it and whether
+// the container fits on the engine
+func (r *ResourceManager) Scores(c *citadel.Container, engines []*citadel.EngineSnapshot) []*citadel.ResourceScore {
+	c = r.sized(c)
+
+	out := []*citadel.ResourceScore{}
+	for _, e := range engines {
+		out = append(out, r.score(c, e))
+	}
+
+	return out
+}
+
+func (r *ResourceManager) score(c *citadel.Container, e *citadel.EngineSnapshot) *citadel.ResourceScore {
+	s := &citadel.ResourceScore{Engine: e.ID}
+
+	if e.Memory < c.Image.Memory || e.Cpus < c.Image.Cpus {
+		s.Reason = "engine has less cpus or memory than the image requests"
+
+		return s
+	}
+
+	cpus, memory := r.used(e)
+
+	s.Cpus = ((cpus + c.Image.Cpus) / e.Cpus) * 100.0
+	s.Memory = ((memory + c.Image.Memory) / e.Memory) * 100.0
+	s.Total = ((s.Cpus + s.Memory) / 200.0) * 100.0
+
+	// disk is only scored on engines that advertise their disk space and
+	// unlike cpus and memory it can not be overcommitted
+	if e.Disk > 0 {
+		s.Disk = ((e.ReservedDisk + c.Image.Disk) / e.Disk) * 100.0
+		if s.Disk > 100.0 {
+			s.Reason = "not enough free disk"
+
+			return s
+		}
+
+		s.Total = ((s.Cpus + s.Memory + s.Disk) / 300.0) * 100.0
+	}
+
+	if s.Total > 100.0 {
+		s.Reason = "not enough free cpus and memory"
+
+		return s
+	}
+
+	s.Fits = true
+
+	return s
+}
+
+// SetStrategy sets the placement strategy for images of the type
+func (r *ResourceManager) SetStrategy(tpe string, s Strategy) {
+	if r.Strategies == nil {
+		r.Strategies = make(map[string]Strategy)
+	}
+
+	r.Strategies[tpe] = s
+}
+
+// strategy returns the strategy requested by the image, or the strategy for the
+// image's type, or the default strategy
+func (r *ResourceManager) strategy(i *citadel.Image) (Strategy, error) {
+	if i.Strategy != "" {
+		return ParseStrategy(i.Strategy)
+	}
+
+	if s := r.Strategies[i.Type]; s != nil {
+		return s, nil
+	}
+
+	if r.Strategy != nil {
+		return r.Strategy, nil
+	}
+
+	return &BinpackStrategy{}, nil
+}
+
+// used returns the cpus and memory of the engine that are considered in use.  When
+// live usage is enabled this is the usage with headroom plus the reservations of the
+// containers that are not started yet and so are missing from the usage.
+func (r *ResourceManager) used(e *citadel.EngineSnapshot) (float64, float64) {
+	if !r.Usage {
+		return e.ReservedCpus, e.ReservedMemory
+	}
+
+	headroom := r.Headroom
+	if headroom <= 0 {
+		headroom = 1
+	}
+
+	return e.CurrentCpu*headroom + e.PendingCpus, e.CurrentMemory*headroom + e.PendingMemory
+}
+
+// blend combines the strategy's score of the engine with the engine's preference
+func (r *ResourceManager) blend(total float64, e *citadel.EngineSnapshot) float64 {
+	return total*(1-r.PreferenceWeight) + e.Preference*100.0*r.PreferenceWeight
+}
+
+// Estimate returns the cpus and memory that the image is estimated to use, or false if
+// there is no estimator or no estimate for the image
+func (r *ResourceManager) Estimate(i *citadel.Image) (float64, float64, bool) {
+	if r.Estimator == nil {
+		return 0, 0, false
+	}
+
+	return r.Estimator.Estimate(i)
+}
+
+// sized returns a copy of the container whose image requests the estimated resources so
+// that the container is placed with them.  The container itself is left unchanged.
+func (r *ResourceManager) sized(c *citadel.Container) *citadel.Container {
+	cpus, memory, ok := r.Estimate(c.Image)
+	if !ok {
+		return c
+	}
+
+	var (
+		i      = *c.Image
+		copied = *c
+	)
+
+	if cpus > 0 {
+		i.Cpus = cpus
+	}
+
+	if memory > 0 {
+		i.Memory = memory
+	}
+
+	copied.Image = &i
+
+	return &copied
+}
@@ -0,0 +1,103 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/citadel/citadel"
+)
+
+func TestPlaceContainerLiveUsage(t *testing.T) {
+	var (
+		container = &citadel.Container{Image: &citadel.Image{Cpus: 1, Memory: 512}}
+
+		// busy has little reserved but is using most of its resources
+		busy = &citadel.EngineSnapshot{ID: "busy", Cpus: 4, Memory: 4096, ReservedCpus: 1, ReservedMemory: 512, CurrentCpu: 2.5, CurrentMemory: 3000}
+		idle = &citadel.EngineSnapshot{ID: "idle", Cpus: 4, Memory: 4096, ReservedCpus: 2, ReservedMemory: 1024}
+	)
+
+	s, err := NewResourceManager().PlaceContainer(container, []*citadel.EngineSnapshot{busy, idle})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.ID != "idle" {
+		t.Fatalf("expected the most reserved engine idle; received %s", s.ID)
+	}
+
+	if _, err := NewUsageResourceManager(1.2).PlaceContainer(container, []*citadel.EngineSnapshot{busy}); err == nil {
+		t.Fatal("expected busy engine to be full when live usage is considered")
+	}
+}
+
+func TestPlaceContainerPendingUsage(t *testing.T) {
+	var (
+		container = &citadel.Container{Image: &citadel.Image{Cpus: 1, Memory: 512}}
+
+		// quiet reserved most of its cpus for running containers that use little of them
+		quiet = &citadel.EngineSnapshot{ID: "quiet", Cpus: 4, Memory: 4096, ReservedCpus: 3.5, ReservedMemory: 3512, CurrentCpu: 1, CurrentMemory: 512}
+
+		// starting has the same usage with containers that are not started yet
+		starting = &citadel.EngineSnapshot{ID: "starting", Cpus: 4, Memory: 4096, ReservedCpus: 3.5, ReservedMemory: 3512, PendingCpus: 2.5, PendingMemory: 3000, CurrentCpu: 1, CurrentMemory: 512}
+	)
+
+	if _, err := NewUsageResourceManager(1).PlaceContainer(container, []*citadel.EngineSnapshot{quiet}); err != nil {
+		t.Fatalf("expected the container to fit on the usage of quiet; received %v", err)
+	}
+
+	if _, err := NewUsageResourceManager(1).PlaceContainer(container, []*citadel.EngineSnapshot{starting}); err == nil {
+		t.Fatal("expected starting to be full with the reservations of its pending containers")
+	}
+}
+
+func TestPlaceContainerDisk(t *testing.T) {
+	var (
+		container = &citadel.Container{Image: &citadel.Image{Cpus: 1, Memory: 512, Disk: 2048}}
+
+		full  = &citadel.EngineSnapshot{ID: "full", Cpus: 4, Memory: 4096, Disk: 10240, ReservedDisk: 9216}
+		empty = &citadel.EngineSnapshot{ID: "empty", Cpus: 4, Memory: 4096, Disk: 10240}
+	)
+
+	s, err := NewResourceManager().PlaceContainer(container, []*citadel.EngineSnapshot{full, empty})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.ID != "empty" {
+		t.Fatalf("expected engine with free disk; received %s", s.ID)
+	}
+}
+
+func TestPlaceContainerPreference(t *testing.T) {
+	var (
+		image     = &citadel.Image{Cpus: 1, Memory: 512, Preferences: []*citadel.Preference{{Constraint: "disk==ssd", Weight: 2}, {Constraint: "zone==a"}}}
+		container = &citadel.Container{Image: image}
+		ssd       = &citadel.Engine{ID: "ssd", Labels: []string{"disk=ssd", "zone=b"}}
+		fuller    = &citadel.Engine{ID: "fuller", Labels: []string{"disk=hdd", "zone=b"}}
+		m         = NewMultiScheduler(&LabelScheduler{})
+	)
+
+	m.AddScorer(&PreferenceScorer{}, 1)
+
+	snapshots := []*citadel.EngineSnapshot{
+		{ID: "ssd", Cpus: 4, Memory: 4096},
+		{ID: "fuller", Cpus: 4, Memory: 4096, ReservedCpus: 2, ReservedMemory: 2048},
+	}
+
+	for i, e := range []*citadel.Engine{ssd, fuller} {
+		score, err := m.Score(image, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		snapshots[i].Preference = score
+	}
+
+	s, err := NewResourceManager().PlaceContainer(container, snapshots)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.ID != "ssd" {
+		t.Fatalf("expected preferred engine ssd over the fuller engine; received %s", s.ID)
+	}
+}
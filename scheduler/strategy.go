@@ -0,0 +1,122 @@
+package scheduler
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/citadel/citadel"
+)
+
+// Candidate is an engine that has the resources to run a container
+type Candidate struct {
+	Engine *citadel.EngineSnapshot
+
+	// Fill is the percentage of the engine's resources in use once the container is placed
+	Fill float64
+
+	// Score is set by the strategy between 0 and 100, higher scores are preferred
+	Score float64
+}
+
+// Strategy decides how containers are spread over the engines that can run them
+type Strategy interface {
+	// Rank sets the score of each of the candidates to run the container
+	Rank(*citadel.Container, []*Candidate)
+}
+
+// BinpackStrategy prefers the fullest engines so that whole engines are kept free
+type BinpackStrategy struct {
+}
+
+func (s *BinpackStrategy) Rank(c *citadel.Container, candidates []*Candidate) {
+	for _, cand := range candidates {
+		cand.Score = cand.Fill
+	}
+}
+
+// SpreadStrategy prefers the emptiest engines so that load is spread evenly
+type SpreadStrategy struct {
+}
+
+func (s *SpreadStrategy) Rank(c *citadel.Container, candidates []*Candidate) {
+	for _, cand := range candidates {
+		cand.Score = 100.0 - cand.Fill
+	}
+}
+
+// RandomStrategy places containers on a random engine that can run them
+type RandomStrategy struct {
+}
+
+func (s *RandomStrategy) Rank(c *citadel.Container, candidates []*Candidate) {
+	for _, cand := range candidates {
+		cand.Score = rand.Float64() * 100.0
+	}
+}
+
+// SpreadLabelStrategy balances the replicas of an image across the values of an engine
+// label such as a zone or rack so that losing all engines with one value does not lose
+// every replica.  Engines without the label are treated as sharing an empty value.
+// Between engines with the same value the emptiest engine is preferred.
+type SpreadLabelStrategy struct {
+	Key string
+}
+
+func (s *SpreadLabelStrategy) Rank(c *citadel.Container, candidates []*Candidate) {
+	replicas := make(map[string]int)
+
+	for _, cand := range candidates {
+		replicas[s.value(cand.Engine)] += cand.Engine.Replicas
+	}
+
+	least := -1
+	for _, r := range replicas {
+		if least == -1 || r < least {
+			least = r
+		}
+	}
+
+	for _, cand := range candidates {
+		r := replicas[s.value(cand.Engine)]
+
+		// label values with the fewest replicas score 100 and the fill of the engine
+		// only breaks ties between engines with the same number of replicas
+		cand.Score = 100.0*float64(least+1)/float64(r+1) - cand.Fill/100.0
+	}
+}
+
+func (s *SpreadLabelStrategy) value(e *citadel.EngineSnapshot) string {
+	for _, l := range e.Labels {
+		parts := strings.SplitN(l, "=", 2)
+		if len(parts) == 2 && parts[0] == s.Key {
+			return parts[1]
+		}
+	}
+
+	return ""
+}
+
+// ParseStrategy returns the strategy for the name: binpack, spread, random or
+// spread:<label key>
+func ParseStrategy(name string) (Strategy, error) {
+	switch name {
+	case "", "binpack":
+		return &BinpackStrategy{}, nil
+	case "spread":
+		return &SpreadStrategy{}, nil
+	case "random":
+		return &RandomStrategy{}, nil
+	}
+
+	if strings.HasPrefix(name, "spread:") {
+		key := strings.TrimPrefix(name, "spread:")
+		if key == "" {
+			return nil, fmt.Errorf("spread strategy requires a label key")
+		}
+
+		return &SpreadLabelStrategy{Key: key}, nil
+	}
+
+	return nil, fmt.Errorf("unknown placement strategy %s", name)
+}
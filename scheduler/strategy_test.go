@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/citadel/citadel"
+)
+
+func place(t *testing.T, r *ResourceManager, image *citadel.Image, engines ...*citadel.EngineSnapshot) string {
+	s, err := r.PlaceContainer(&citadel.Container{Image: image}, engines)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s.ID
+}
+
+func TestStrategies(t *testing.T) {
+	var (
+		r     = NewResourceManager()
+		empty = &citadel.EngineSnapshot{ID: "empty", Cpus: 4, Memory: 4096}
+		full  = &citadel.EngineSnapshot{ID: "full", Cpus: 4, Memory: 4096, ReservedCpus: 2, ReservedMemory: 2048}
+	)
+
+	if id := place(t, r, &citadel.Image{Cpus: 1, Memory: 512}, empty, full); id != "full" {
+		t.Fatalf("expected binpack to choose full; received %s", id)
+	}
+
+	if id := place(t, r, &citadel.Image{Cpus: 1, Memory: 512, Strategy: "spread"}, empty, full); id != "empty" {
+		t.Fatalf("expected spread to choose empty; received %s", id)
+	}
+
+	r.SetStrategy("web", &SpreadStrategy{})
+
+	if id := place(t, r, &citadel.Image{Cpus: 1, Memory: 512, Type: "web"}, empty, full); id != "empty" {
+		t.Fatalf("expected spread for type web to choose empty; received %s", id)
+	}
+
+	if _, err := ParseStrategy("pack"); err == nil {
+		t.Fatal("expected error for unknown strategy")
+	}
+}
+
+func TestSpreadLabelStrategy(t *testing.T) {
+	var (
+		r     = NewResourceManager()
+		image = &citadel.Image{Cpus: 1, Memory: 512, Strategy: "spread:zone"}
+
+		a1 = &citadel.EngineSnapshot{ID: "a1", Labels: []string{"zone=a"}, Cpus: 4, Memory: 4096, Replicas: 1}
+		a2 = &citadel.EngineSnapshot{ID: "a2", Labels: []string{"zone=a"}, Cpus: 4, Memory: 4096}
+		b1 = &citadel.EngineSnapshot{ID: "b1", Labels: []string{"zone=b"}, Cpus: 4, Memory: 4096, ReservedCpus: 3, ReservedMemory: 3072}
+		b2 = &citadel.EngineSnapshot{ID: "b2", Labels: []string{"zone=b"}, Cpus: 4, Memory: 4096, ReservedCpus: 1, ReservedMemory: 1024}
+	)
+
+	// zone b has no replicas and b2 is the emptiest engine in zone b
+	if id := place(t, r, image, a1, a2, b1, b2); id != "b2" {
+		t.Fatalf("expected spread:zone to choose b2; received %s", id)
+	}
+
+	b2.Replicas = 1
+	a2.Replicas = 1
+	b1.ReservedCpus, b1.ReservedMemory = 0, 0
+
+	// zone a now has two replicas and zone b one with b1 now the emptiest engine
+	if id := place(t, r, image, a1, a2, b1, b2); id != "b1" {
+		t.Fatalf("expected spread:zone to choose b1; received %s", id)
+	}
+}
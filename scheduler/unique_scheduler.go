@@ -10,17 +10,22 @@ import (
 type UniqueScheduler struct {
 }
 
-func (u *UniqueScheduler) Schedule(c *citadel.Image, e *citadel.Engine) (bool, error) {
+func (u *UniqueScheduler) Schedule(c *citadel.Image, e *citadel.Engine) (*citadel.Decision, error) {
 	containers, err := e.ListContainers(false)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
 	if u.hasImage(c, containers) {
-		return false, nil
+		return citadel.Reject("unique", "engine already runs %s", c.Name), nil
 	}
 
-	return true, nil
+	return citadel.Accept("unique"), nil
+}
+
+// Exclusive returns true because no two containers of an image share an engine
+func (u *UniqueScheduler) Exclusive(i *citadel.Image) bool {
+	return true
 }
 
 func (u *UniqueScheduler) hasImage(i *citadel.Image, containers []*citadel.Container) bool {
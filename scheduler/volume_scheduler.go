@@ -0,0 +1,28 @@
+package scheduler
+
+import "github.com/citadel/citadel"
+
+// VolumeScheduler only returns engines that host all the named volumes that
+// the image binds
+type VolumeScheduler struct {
+}
+
+func (v *VolumeScheduler) Schedule(c *citadel.Image, e *citadel.Engine) (*citadel.Decision, error) {
+	for _, name := range c.NamedVolumes() {
+		if !v.hasVolume(e, name) {
+			return citadel.Reject("volume", "engine does not host volume %s", name), nil
+		}
+	}
+
+	return citadel.Accept("volume"), nil
+}
+
+func (v *VolumeScheduler) hasVolume(e *citadel.Engine, name string) bool {
+	for _, vol := range e.Volumes {
+		if vol == name {
+			return true
+		}
+	}
+
+	return false
+}
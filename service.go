@@ -0,0 +1,19 @@
+package citadel
+
+import "fmt"
+
+// Service is the desired state of a set of containers that the cluster keeps running
+type Service struct {
+	// Name is the unique name of the service
+	Name string `json:"name,omitempty"`
+
+	// Image is the configuration from which the containers of the service are created
+	Image *Image `json:"image,omitempty"`
+
+	// Replicas is the number of containers of the service to keep running
+	Replicas int `json:"replicas,omitempty"`
+}
+
+func (s *Service) String() string {
+	return fmt.Sprintf("service %s image %s replicas %d", s.Name, s.Image.Name, s.Replicas)
+}
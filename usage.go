@@ -0,0 +1,185 @@
+package citadel
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ContainerUsage is the resource usage of a running container sampled from the
+// docker stats API
+type ContainerUsage struct {
+	// Container is the container that was sampled
+	Container *Container `json:"container,omitempty"`
+
+	// Cpus is the number of cpus the container used since the previous sample
+	Cpus float64 `json:"cpus,omitempty"`
+
+	// Interval is the time since the previous sample of the container and is zero
+	// for the first sample, when the cpu usage is not known
+	Interval time.Duration `json:"interval,omitempty"`
+
+	// Memory is the memory in MB used by the container
+	Memory float64 `json:"memory,omitempty"`
+
+	// Time is when the sample was taken
+	Time time.Time `json:"time,omitempty"`
+}
+
+// Usage is the resource usage of all the containers running on an engine
+type Usage struct {
+	// Cpus is the total number of cpus used by the containers
+	Cpus float64 `json:"cpus,omitempty"`
+
+	// Memory is the total memory in MB used by the containers
+	Memory float64 `json:"memory,omitempty"`
+
+	// Containers is the usage of each container on the engine
+	Containers []*ContainerUsage `json:"containers,omitempty"`
+
+	// Time is when the sample was taken
+	Time time.Time `json:"time,omitempty"`
+}
+
+// cpuSample is the cumulative cpu time of a container used to compute the
+// cpu usage between two samples
+type cpuSample struct {
+	total uint64
+	time  time.Time
+}
+
+type containerStats struct {
+	CpuStats struct {
+		CpuUsage struct {
+			TotalUsage uint64 `json:"total_usage"`
+		} `json:"cpu_usage"`
+	} `json:"cpu_stats"`
+
+	MemoryStats struct {
+		Usage uint64 `json:"usage"`
+	} `json:"memory_stats"`
+}
+
+// statsConcurrency is the number of container stats read from an engine at the same time
+const statsConcurrency = 8
+
+// SampleUsage reads the stats of every running container on the engine and stores
+// the aggregated usage of the engine.  Cpu usage is computed from the cpu time used
+// since the previous sample so the first sample of a container reports no cpu usage.
+// Containers whose stats can not be read, for example because they exited after being
+// listed, are left out of the sample.  An error is only returned if no stats could be read.
+func (e *Engine) SampleUsage() (*Usage, error) {
+	containers, err := e.ListContainers(false)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		now     = time.Now()
+		usage   = &Usage{Time: now}
+		samples = make(map[string]*cpuSample)
+		stats   = make([]*containerStats, len(containers))
+		errs    = make([]error, len(containers))
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, statsConcurrency)
+	)
+
+	e.usageMux.Lock()
+	previous := e.cpuSamples
+	e.usageMux.Unlock()
+
+	for i, c := range containers {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int, id string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			stats[i], errs[i] = e.containerStats(id)
+		}(i, c.ID)
+	}
+
+	wg.Wait()
+
+	for i, c := range containers {
+		if errs[i] != nil {
+			// keep the previous sample so the next cpu usage covers the skipped interval
+			if p := previous[c.ID]; p != nil {
+				samples[c.ID] = p
+			}
+
+			err = errs[i]
+
+			continue
+		}
+
+		cu := &ContainerUsage{
+			Container: c,
+			Memory:    float64(stats[i].MemoryStats.Usage) / 1024 / 1024,
+			Time:      now,
+		}
+
+		total := stats[i].CpuStats.CpuUsage.TotalUsage
+		if p := previous[c.ID]; p != nil && total >= p.total {
+			if elapsed := now.Sub(p.time); elapsed > 0 {
+				cu.Cpus = float64(total-p.total) / float64(elapsed.Nanoseconds())
+				cu.Interval = elapsed
+			}
+		}
+
+		samples[c.ID] = &cpuSample{total: total, time: now}
+
+		usage.Cpus += cu.Cpus
+		usage.Memory += cu.Memory
+		usage.Containers = append(usage.Containers, cu)
+	}
+
+	if len(containers) > 0 && len(usage.Containers) == 0 {
+		return nil, err
+	}
+
+	e.usageMux.Lock()
+	e.usage = usage
+	e.cpuSamples = samples
+	e.usageMux.Unlock()
+
+	return usage, nil
+}
+
+// Usage returns the last usage sampled for the engine or nil if the engine
+// has not been sampled
+func (e *Engine) Usage() *Usage {
+	e.usageMux.Lock()
+	defer e.usageMux.Unlock()
+
+	return e.usage
+}
+
+// containerStats reads a single stats sample for the container.  Older versions of the
+// API ignore stream=false and keep streaming so only the first sample is decoded.
+func (e *Engine) containerStats(id string) (*containerStats, error) {
+	if e.client == nil {
+		return nil, ErrEngineNotConnected
+	}
+
+	resp, err := e.client.HTTPClient.Get(fmt.Sprintf("%s/containers/%s/stats?stream=false", e.client.URL.String(), id))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("unable to read stats for container %s: %s", id, resp.Status)
+	}
+
+	var stats *containerStats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
@@ -1,6 +1,7 @@
 package citadel
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
@@ -80,6 +81,10 @@ func FromDockerContainer(id, image string, engine *Engine) (*Container, error) {
 
 	var (
 		cType       = ""
+		service     = ""
+		priority    = 0
+		tenant      = ""
+		disk        = 0.0
 		state       = "stopped"
 		networkMode = "bridge"
 		labels      = []string{}
@@ -94,7 +99,15 @@ func FromDockerContainer(id, image string, engine *Engine) (*Container, error) {
 		case "_citadel_type":
 			cType = v
 		case "_citadel_labels":
-			labels = strings.Split(v, ",")
+			labels = decodeLabels(v)
+		case "_citadel_disk":
+			disk, _ = strconv.ParseFloat(v, 64)
+		case "_citadel_service":
+			service = v
+		case "_citadel_priority":
+			priority, _ = strconv.Atoi(v)
+		case "_citadel_tenant":
+			tenant = v
 		case "HOME", "DEBIAN_FRONTEND", "PATH":
 			continue
 		default:
@@ -116,14 +129,16 @@ func FromDockerContainer(id, image string, engine *Engine) (*Container, error) {
 	}
 
 	container := &Container{
-		ID:     id,
-		Engine: engine,
-		Name:   info.Name,
-		State:  state,
+		ID:       id,
+		Engine:   engine,
+		Name:     info.Name,
+		State:    state,
+		ExitCode: info.State.ExitCode,
 		Image: &Image{
 			Name:        image,
 			Cpus:        float64(info.Config.CpuShares) / 100.0 * engine.Cpus,
 			Memory:      float64(info.Config.Memory / 1024 / 1024),
+			Disk:        disk,
 			Volumes:     vols,
 			Environment: env,
 			Entrypoint:  info.Config.Entrypoint,
@@ -131,6 +146,9 @@ func FromDockerContainer(id, image string, engine *Engine) (*Container, error) {
 			Domainname:  info.Config.Domainname,
 			Type:        cType,
 			Labels:      labels,
+			Service:     service,
+			Priority:    priority,
+			Tenant:      tenant,
 			NetworkMode: networkMode,
 			Publish:     info.HostConfig.PublishAllPorts,
 			RestartPolicy: RestartPolicy{
@@ -148,6 +166,35 @@ func FromDockerContainer(id, image string, engine *Engine) (*Container, error) {
 	return container, nil
 }
 
+// encodeLabels encodes the labels as json because constraints may contain commas
+func encodeLabels(labels []string) string {
+	if labels == nil {
+		labels = []string{}
+	}
+
+	data, _ := json.Marshal(labels)
+
+	return string(data)
+}
+
+// decodeLabels decodes labels encoded by encodeLabels and the comma separated
+// labels of containers started by older versions
+func decodeLabels(v string) []string {
+	labels := []string{}
+
+	if strings.HasPrefix(v, "[") {
+		if err := json.Unmarshal([]byte(v), &labels); err == nil {
+			return labels
+		}
+	}
+
+	if v == "" {
+		return labels
+	}
+
+	return strings.Split(v, ",")
+}
+
 func ParseImageName(name string) *ImageInfo {
 	imageInfo := &ImageInfo{
 		Name: name,
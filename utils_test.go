@@ -97,3 +97,15 @@ func TestParseImageNameCustomRegistryPortNoTag(t *testing.T) {
 		t.Fatalf("expected tag latest; received %s", imageInfo.Tag)
 	}
 }
+
+func TestDecodeLabels(t *testing.T) {
+	labels := decodeLabels(encodeLabels([]string{"zone in (a, b)", "local"}))
+	if len(labels) != 2 || labels[0] != "zone in (a, b)" {
+		t.Fatalf("expected labels to round trip; received %v", labels)
+	}
+
+	labels = decodeLabels("local,us-east-1")
+	if len(labels) != 2 || labels[1] != "us-east-1" {
+		t.Fatalf("expected comma separated labels; received %v", labels)
+	}
+}